@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,6 +12,8 @@ import (
 	"github.com/neokofg/callap-backend/internal/infrastructure/cache/redis"
 	"github.com/neokofg/callap-backend/internal/infrastructure/database/postgresql"
 	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber"
+	"github.com/neokofg/callap-backend/internal/infrastructure/mail"
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
 	"go.uber.org/zap"
 )
 
@@ -45,11 +48,41 @@ func Run(cfg *config.Config, logger *zap.Logger) {
 	}
 	logger.Info("redis connected")
 
+	mailer := mail.NewMailer(mail.Config{
+		Driver:   mail.Driver(cfg.Mail.Driver),
+		Host:     cfg.Mail.Host,
+		Port:     cfg.Mail.Port,
+		Username: cfg.Mail.Username,
+		Password: cfg.Mail.Password,
+		From:     cfg.Mail.From,
+		Dir:      cfg.Mail.Dir,
+	}, logger)
+
+	store, err := storage.NewStore(storage.Config{
+		Driver:    storage.Driver(cfg.Storage.Driver),
+		Dir:       cfg.Storage.Dir,
+		Endpoint:  cfg.Storage.Endpoint,
+		Region:    cfg.Storage.Region,
+		Bucket:    cfg.Storage.Bucket,
+		AccessKey: cfg.Storage.AccessKey,
+		SecretKey: cfg.Storage.SecretKey,
+		UseSSL:    cfg.Storage.UseSSL,
+	})
+	if err != nil {
+		logger.Fatal("failed to init storage", zap.Error(err))
+	}
+
 	repositories := repository.NewRepositories(pool, rdb)
-	services := service.NewServices(cfg, repositories, logger)
+	services := service.NewServices(cfg, repositories, mailer, store, logger)
 
 	fiber.InitFiber(cfg, logger, services)
 
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go services.AccountDeletionService.Run(jobs)
+	go services.FriendService.RunRequestExpiry(jobs)
+	go services.ExportService.RunExpiry(jobs)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
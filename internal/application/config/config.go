@@ -9,20 +9,40 @@ const (
 )
 
 type Config struct {
-	Env            Env        `env:"ENV"             env-required:"true"`
-	ContextTimeout int        `env:"CONTEXT_TIMEOUT" env-required:"true"`
-	Host           string     `env:"HOST"            env-required:"true"`
-	Port           string     `env:"PORT"            env-required:"true"`
-	JWT            JWT        `                      env-required:"true" env-prefix:"JWT_"`
-	PostgreSQL     PostgreSQL `                      env-required:"true" env-prefix:"POSTGRES_"`
-	Redis          Redis      `                      env-required:"true" env-prefix:"REDIS_"`
+	Env              Env        `env:"ENV"                env-required:"true"`
+	ContextTimeout   int        `env:"CONTEXT_TIMEOUT"    env-required:"true"`
+	Host             string     `env:"HOST"               env-required:"true"`
+	Port             string     `env:"PORT"               env-required:"true"`
+	FrontendURL      string     `env:"FRONTEND_URL"       env-default:"http://localhost:3000"`
+	EncryptionKey    string     `env:"ENCRYPTION_KEY"     env-required:"true"`
+	TOTPIssuer       string     `env:"TOTP_ISSUER"        env-default:"Callap"`
+	FriendRequestTTL int        `env:"FRIEND_REQUEST_TTL" env-default:"30"`
+	JWT              JWT        `                         env-required:"true" env-prefix:"JWT_"`
+	Password         Password   `                                             env-prefix:"PASSWORD_"`
+	PostgreSQL       PostgreSQL `                         env-required:"true" env-prefix:"POSTGRES_"`
+	Redis            Redis      `                         env-required:"true" env-prefix:"REDIS_"`
+	Mail             Mail       `                                             env-prefix:"MAIL_"`
+	OAuth            OAuth      `                                             env-prefix:"OAUTH_"`
+	WebAuthn         WebAuthn   `                                             env-prefix:"WEBAUTHN_"`
+	Storage          Storage    `                                             env-prefix:"STORAGE_"`
 }
 
 type JWT struct {
-	Secret          string `env:"SECRET"            env-required:"true"`
-	AccessTokenTTL  int    `env:"ACCESS_TOKEN_TTL"  env-required:"true"`
-	RefreshTokenTTL int    `env:"REFRESH_TOKEN_TTL" env-required:"true"`
-	Issuer          string `env:"ISSUER"            env-default:"backend"`
+	Secret          string   `env:"SECRET"`
+	Algorithm       string   `env:"ALGORITHM"         env-default:"HS256"`
+	PrivateKeyPath  string   `env:"PRIVATE_KEY_PATH"`
+	PublicKeyPaths  []string `env:"PUBLIC_KEY_PATHS"  env-separator:","`
+	AccessTokenTTL  int      `env:"ACCESS_TOKEN_TTL"  env-required:"true"`
+	RefreshTokenTTL int      `env:"REFRESH_TOKEN_TTL" env-required:"true"`
+	Issuer          string   `env:"ISSUER"            env-default:"backend"`
+}
+
+type Password struct {
+	Algorithm     string `env:"ALGORITHM"      env-default:"argon2id"`
+	Argon2Memory  uint32 `env:"ARGON2_MEMORY"  env-default:"65536"`
+	Argon2Time    uint32 `env:"ARGON2_TIME"    env-default:"3"`
+	Argon2Threads uint8  `env:"ARGON2_THREADS" env-default:"2"`
+	BcryptCost    int    `env:"BCRYPT_COST"    env-default:"10"`
 }
 
 type PostgreSQL struct {
@@ -47,3 +67,47 @@ type Redis struct {
 	Password string `env:"PASSWORD" env-required:"true"`
 	DB       int    `env:"DB" env-required:"true"`
 }
+
+type Mail struct {
+	Driver   string `env:"DRIVER"   env-default:"log"`
+	Host     string `env:"HOST"`
+	Port     int    `env:"PORT"     env-default:"587"`
+	Username string `env:"USERNAME"`
+	Password string `env:"PASSWORD"`
+	From     string `env:"FROM"     env-default:"no-reply@callap.local"`
+	Dir      string `env:"DIR"`
+}
+
+type Storage struct {
+	Driver    string `env:"DRIVER"     env-default:"local"`
+	Dir       string `env:"DIR"        env-default:"./tmp/storage"`
+	Endpoint  string `env:"ENDPOINT"`
+	Region    string `env:"REGION"`
+	Bucket    string `env:"BUCKET"`
+	AccessKey string `env:"ACCESS_KEY"`
+	SecretKey string `env:"SECRET_KEY"`
+	UseSSL    bool   `env:"USE_SSL"    env-default:"true"`
+}
+
+type OAuth struct {
+	// RedirectURL is the frontend page providers send the user back to; the
+	// provider name is appended as the last path segment.
+	RedirectURL string        `env:"REDIRECT_URL" env-default:"http://localhost:3000/oauth/callback"`
+	Google      OAuthProvider `                   env-prefix:"GOOGLE_"`
+	GitHub      OAuthProvider `                   env-prefix:"GITHUB_"`
+	OIDC        OAuthProvider `                   env-prefix:"OIDC_"`
+}
+
+type OAuthProvider struct {
+	ClientID     string `env:"CLIENT_ID"`
+	ClientSecret string `env:"CLIENT_SECRET"`
+	Issuer       string `env:"ISSUER"`
+}
+
+type WebAuthn struct {
+	// RPID is the domain passkeys are bound to; it must be the frontend host or
+	// a registrable suffix of it.
+	RPID    string   `env:"RP_ID"   env-default:"localhost"`
+	RPName  string   `env:"RP_NAME" env-default:"Callap"`
+	Origins []string `env:"ORIGINS" env-default:"http://localhost:3000" env-separator:","`
+}
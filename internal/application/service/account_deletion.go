@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour
+	accountPurgeInterval       = time.Hour
+	accountPurgeBatch          = 100
+)
+
+// AccountDeletionService deletes accounts in two steps: Schedule flags the
+// account and logs it out everywhere, and the purge job started by Run removes
+// it once the grace period is over. Logging in before that cancels the
+// deletion (see SessionService.Start).
+type AccountDeletionService struct {
+	cTimeout       time.Duration
+	userRepo       *repository.UserRepository
+	apiTokenRepo   *repository.APITokenRepository
+	sessionService *SessionService
+	mailService    *MailService
+	store          storage.Store
+	logger         *zap.Logger
+}
+
+func NewAccountDeletionService(
+	cTimeout time.Duration,
+	userRepo *repository.UserRepository,
+	apiTokenRepo *repository.APITokenRepository,
+	sessionService *SessionService,
+	mailService *MailService,
+	store storage.Store,
+	logger *zap.Logger,
+) *AccountDeletionService {
+	return &AccountDeletionService{
+		cTimeout:       cTimeout,
+		userRepo:       userRepo,
+		apiTokenRepo:   apiTokenRepo,
+		sessionService: sessionService,
+		mailService:    mailService,
+		store:          store,
+		logger:         logger,
+	}
+}
+
+// Schedule flags the user for deletion and returns when the account will be
+// purged. API tokens are revoked right away since using them is not a login
+// and would otherwise keep the account usable during the grace period.
+func (ads *AccountDeletionService) Schedule(c context.Context, user entity.User) (time.Time, error) {
+	c, cancel := context.WithTimeout(c, ads.cTimeout)
+	defer cancel()
+
+	deletedAt, err := ads.userRepo.ScheduleDeletion(c, user.Id.String())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err = ads.apiTokenRepo.DeleteAll(c, user.Id.String()); err != nil {
+		return time.Time{}, err
+	}
+	if err = ads.sessionService.RevokeAll(c, user.Id.String()); err != nil {
+		return time.Time{}, err
+	}
+
+	purgeAt := deletedAt.Add(AccountDeletionGracePeriod)
+	ads.mailService.SendAccountDeletionScheduled(user.Email, purgeAt)
+	return purgeAt, nil
+}
+
+// Run purges expired accounts right away and then every accountPurgeInterval
+// until c is done.
+func (ads *AccountDeletionService) Run(c context.Context) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := ads.Purge(c)
+		if err != nil {
+			ads.logger.Error("Failed to purge deleted accounts", zap.Error(err))
+		} else if purged > 0 {
+			ads.logger.Info("Purged deleted accounts", zap.Int64("count", purged))
+		}
+
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes every account whose grace period is over. The rows of the
+// user go with it through ON DELETE CASCADE, except for their messages, which
+// stay in the conversations of the other participants as sent by
+// "Deleted user". Profile images of the user are removed as well; data
+// exports are left to ExportService.RunExpiry, which deletes them within a
+// day of their creation.
+func (ads *AccountDeletionService) Purge(c context.Context) (int64, error) {
+	before := time.Now().UTC().Add(-AccountDeletionGracePeriod)
+
+	var total int64
+	for {
+		batchCtx, cancel := context.WithTimeout(c, ads.cTimeout)
+		users, err := ads.userRepo.PurgeDeleted(batchCtx, before, accountPurgeBatch)
+		for _, user := range users {
+			ads.deleteFiles(batchCtx, user)
+		}
+		cancel()
+		total += int64(len(users))
+		if err != nil || len(users) < accountPurgeBatch {
+			return total, err
+		}
+	}
+}
+
+func (ads *AccountDeletionService) deleteFiles(c context.Context, user entity.User) {
+	userId := user.Id.String()
+	err := errors.Join(
+		deleteImage(c, ads.store, avatarImage, userId, user.AvatarId),
+		deleteImage(c, ads.store, bannerImage, userId, user.BannerId),
+	)
+	if err != nil {
+		ads.logger.Error("Failed to delete files of purged account", zap.Error(err), zap.String("userId", userId))
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// APITokenPrefix tells API tokens apart from JWTs in the Authorization
+	// header without having to parse them.
+	APITokenPrefix      = "cap_"
+	apiTokenPrefixLen   = len(APITokenPrefix) + 8
+	apiTokenMaxPerUser  = 50
+	apiTokenMaxLifetime = 365 * 24 * time.Hour
+)
+
+const (
+	ScopeUserRead           = "user:read"
+	ScopeFriendsRead        = "friends:read"
+	ScopeFriendsWrite       = "friends:write"
+	ScopeConversationsRead  = "conversations:read"
+	ScopeConversationsWrite = "conversations:write"
+	ScopeMessagesRead       = "messages:read"
+	ScopeMessagesWrite      = "messages:write"
+)
+
+var APITokenScopes = []string{
+	ScopeUserRead,
+	ScopeFriendsRead,
+	ScopeFriendsWrite,
+	ScopeConversationsRead,
+	ScopeConversationsWrite,
+	ScopeMessagesRead,
+	ScopeMessagesWrite,
+}
+
+var (
+	ErrInvalidAPIToken  = errors.New("invalid or expired api token")
+	ErrUnknownScope     = errors.New("unknown scope")
+	ErrTooManyAPITokens = errors.New("api token limit reached")
+)
+
+type APITokenService struct {
+	cTimeout time.Duration
+	repo     *repository.APITokenRepository
+}
+
+func NewAPITokenService(cTimeout time.Duration, repo *repository.APITokenRepository) *APITokenService {
+	return &APITokenService{
+		cTimeout: cTimeout,
+		repo:     repo,
+	}
+}
+
+// Create issues a new token and returns it in plain text along with its
+// stored form. The plain token cannot be recovered afterwards.
+func (ats *APITokenService) Create(c context.Context, userId string, name string, scopes []string, lifetime time.Duration) (string, entity.APIToken, error) {
+	c, cancel := context.WithTimeout(c, ats.cTimeout)
+	defer cancel()
+
+	for _, scope := range scopes {
+		if !slices.Contains(APITokenScopes, scope) {
+			return "", entity.APIToken{}, ErrUnknownScope
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	count, err := ats.repo.Count(c, userId)
+	if err != nil {
+		return "", entity.APIToken{}, err
+	}
+	if count >= apiTokenMaxPerUser {
+		return "", entity.APIToken{}, ErrTooManyAPITokens
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return "", entity.APIToken{}, err
+	}
+	plain := APITokenPrefix + secret
+
+	token := entity.APIToken{
+		UserId:    ulid.MustParse(userId),
+		Name:      name,
+		Prefix:    plain[:apiTokenPrefixLen],
+		TokenHash: hashToken(plain),
+		Scopes:    scopes,
+	}
+	if lifetime > 0 {
+		expiresAt := time.Now().UTC().Add(min(lifetime, apiTokenMaxLifetime))
+		token.ExpiresAt = &expiresAt
+	}
+
+	token, err = ats.repo.Create(c, token)
+	if err != nil {
+		return "", entity.APIToken{}, err
+	}
+	return plain, token, nil
+}
+
+func (ats *APITokenService) Authenticate(c context.Context, plain string) (entity.APIToken, error) {
+	c, cancel := context.WithTimeout(c, ats.cTimeout)
+	defer cancel()
+
+	if !strings.HasPrefix(plain, APITokenPrefix) {
+		return entity.APIToken{}, ErrInvalidAPIToken
+	}
+
+	token, err := ats.repo.GetByHash(c, hashToken(plain))
+	if err != nil {
+		return entity.APIToken{}, ErrInvalidAPIToken
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return entity.APIToken{}, ErrInvalidAPIToken
+	}
+
+	if err = ats.repo.Touch(c, token.Id.String()); err != nil {
+		return entity.APIToken{}, err
+	}
+	return token, nil
+}
+
+func (ats *APITokenService) List(c context.Context, userId string) ([]entity.APIToken, error) {
+	c, cancel := context.WithTimeout(c, ats.cTimeout)
+	defer cancel()
+
+	return ats.repo.List(c, userId)
+}
+
+func (ats *APITokenService) Revoke(c context.Context, userId string, id string) error {
+	c, cancel := context.WithTimeout(c, ats.cTimeout)
+	defer cancel()
+
+	return ats.repo.Delete(c, userId, id)
+}
+
+func (ats *APITokenService) RevokeAll(c context.Context, userId string) error {
+	c, cancel := context.WithTimeout(c, ats.cTimeout)
+	defer cancel()
+
+	return ats.repo.DeleteAll(c, userId)
+}
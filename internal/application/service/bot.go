@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/pkg/encryption"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	botMaxPerOwner = 10
+	botTokenName   = "bot"
+	// botEmailDomain is reserved (RFC 2606) so bot placeholder addresses can
+	// never receive mail nor collide with a real account.
+	botEmailDomain = "bots.invalid"
+)
+
+var (
+	ErrTooManyBots          = errors.New("bot limit reached")
+	ErrInvalidWebhookURL    = errors.New("webhook url must be an absolute https url on a public address")
+	ErrBotContactNotAllowed = errors.New("bots can only talk to their owner and friends")
+)
+
+type BotService struct {
+	cTimeout        time.Duration
+	repo            *repository.BotRepository
+	apiTokenService *APITokenService
+	cipher          *encryption.Cipher
+}
+
+func NewBotService(
+	cTimeout time.Duration,
+	repo *repository.BotRepository,
+	apiTokenService *APITokenService,
+	cipher *encryption.Cipher,
+) *BotService {
+	return &BotService{
+		cTimeout:        cTimeout,
+		repo:            repo,
+		apiTokenService: apiTokenService,
+		cipher:          cipher,
+	}
+}
+
+// Create registers a bot owned by ownerId and returns it with its first
+// token. Bots have no password and a placeholder email, so the token is
+// their only way to authenticate.
+func (bs *BotService) Create(c context.Context, ownerId string, name string) (entity.Bot, string, error) {
+	c, cancel := context.WithTimeout(c, bs.cTimeout)
+	defer cancel()
+
+	count, err := bs.repo.Count(c, ownerId)
+	if err != nil {
+		return entity.Bot{}, "", err
+	}
+	if count >= botMaxPerOwner {
+		return entity.Bot{}, "", ErrTooManyBots
+	}
+
+	ownerUlid, err := ulid.Parse(ownerId)
+	if err != nil {
+		return entity.Bot{}, "", err
+	}
+
+	id := ulid.Make()
+	var bot entity.User
+	err = withRandomTag(c, bs.repo, name, func(tag string) error {
+		var err error
+		bot, err = bs.repo.Create(c, entity.User{
+			Id:         id,
+			Name:       name,
+			Tag:        tag,
+			Email:      fmt.Sprintf("%s@%s", id.String(), botEmailDomain),
+			BotOwnerId: &ownerUlid,
+		})
+		return err
+	})
+	if err != nil {
+		return entity.Bot{}, "", err
+	}
+
+	token, _, err := bs.apiTokenService.Create(c, bot.Id.String(), botTokenName, APITokenScopes, 0)
+	if err != nil {
+		return entity.Bot{}, "", err
+	}
+
+	return entity.Bot{
+		Id:        bot.Id,
+		Name:      bot.Name,
+		Tag:       bot.Tag,
+		CreatedAt: bot.CreatedAt,
+	}, token, nil
+}
+
+func (bs *BotService) List(c context.Context, ownerId string) ([]entity.Bot, error) {
+	c, cancel := context.WithTimeout(c, bs.cTimeout)
+	defer cancel()
+
+	return bs.repo.List(c, ownerId)
+}
+
+// RegenerateToken revokes every token of the bot and issues a new one.
+func (bs *BotService) RegenerateToken(c context.Context, ownerId string, id string) (string, error) {
+	c, cancel := context.WithTimeout(c, bs.cTimeout)
+	defer cancel()
+
+	if _, err := bs.repo.GetOwned(c, ownerId, id); err != nil {
+		return "", err
+	}
+	if err := bs.apiTokenService.RevokeAll(c, id); err != nil {
+		return "", err
+	}
+
+	token, _, err := bs.apiTokenService.Create(c, id, botTokenName, APITokenScopes, 0)
+	return token, err
+}
+
+// SetWebhook points the bot's outgoing webhook at rawURL and returns the new
+// signing secret. An empty rawURL removes the webhook.
+func (bs *BotService) SetWebhook(c context.Context, ownerId string, id string, rawURL string) (string, error) {
+	c, cancel := context.WithTimeout(c, bs.cTimeout)
+	defer cancel()
+
+	if rawURL == "" {
+		return "", bs.repo.SetWebhook(c, ownerId, id, nil, nil)
+	}
+
+	if err := checkWebhookURL(c, rawURL); err != nil {
+		return "", err
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := bs.cipher.Encrypt(secret)
+	if err != nil {
+		return "", err
+	}
+
+	if err = bs.repo.SetWebhook(c, ownerId, id, &rawURL, &encrypted); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (bs *BotService) Delete(c context.Context, ownerId string, id string) error {
+	c, cancel := context.WithTimeout(c, bs.cTimeout)
+	defer cancel()
+
+	return bs.repo.Delete(c, ownerId, id)
+}
@@ -2,23 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/neokofg/callap-backend/internal/domain/entity"
 	"github.com/neokofg/callap-backend/internal/domain/repository"
 )
 
+var ErrNotParticipant = errors.New("not a participant of this conversation")
+
 type ConversationService struct {
 	cTimeout         time.Duration
 	repo             *repository.ConversationRepository
+	userRepo         *repository.UserRepository
+	friendRepo       *repository.FriendRepository
 	websocketService *WebsocketService
+	webhookService   *WebhookService
 }
 
-func NewConversationService(timeout time.Duration, repo *repository.ConversationRepository, websocketService *WebsocketService) *ConversationService {
+func NewConversationService(
+	timeout time.Duration,
+	repo *repository.ConversationRepository,
+	userRepo *repository.UserRepository,
+	friendRepo *repository.FriendRepository,
+	websocketService *WebsocketService,
+	webhookService *WebhookService,
+) *ConversationService {
 	return &ConversationService{
 		cTimeout:         timeout,
 		repo:             repo,
+		userRepo:         userRepo,
+		friendRepo:       friendRepo,
 		websocketService: websocketService,
+		webhookService:   webhookService,
 	}
 }
 
@@ -40,6 +56,14 @@ func (cs *ConversationService) NewMessage(c context.Context, userId string, id s
 	c, cancel := context.WithTimeout(c, cs.cTimeout)
 	defer cancel()
 
+	isParticipant, err := cs.repo.IsParticipant(c, id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+
 	msg, err := cs.repo.NewMessage(c, userId, id, content)
 	if err != nil {
 		return nil, err
@@ -60,15 +84,20 @@ func (cs *ConversationService) NewMessage(c context.Context, userId string, id s
 		ConversationId: msg.ConversationId,
 	}
 
+	event := Message{
+		Type:   "newmsg",
+		UserID: userId,
+		Data:   wsMsg,
+	}
+
+	var recipients []string
 	for _, participant := range participants {
 		if participant.UserId.String() != userId {
-			cs.websocketService.SendToUser(participant.UserId.String(), Message{
-				Type:   "newmsg",
-				UserID: userId,
-				Data:   wsMsg,
-			})
+			cs.websocketService.SendToUser(participant.UserId.String(), event)
+			recipients = append(recipients, participant.UserId.String())
 		}
 	}
+	cs.webhookService.Deliver(recipients, event)
 
 	return msg, nil
 }
@@ -98,5 +127,22 @@ func (cs *ConversationService) GetOrCreate(c context.Context, userId string, tar
 	c, cancel := context.WithTimeout(c, cs.cTimeout)
 	defer cancel()
 
+	user, err := cs.userRepo.GetById(c, userId)
+	if err != nil {
+		return "", err
+	}
+	// Bots cannot cold-message people: besides their owner they only reach
+	// users who accepted their friendship. Anyone may still open a
+	// conversation with a bot.
+	if user.IsBot && (user.BotOwnerId == nil || user.BotOwnerId.String() != targetId) {
+		areFriends, err := cs.friendRepo.AreFriends(c, userId, targetId)
+		if err != nil {
+			return "", err
+		}
+		if !areFriends {
+			return "", ErrBotContactNotAllowed
+		}
+	}
+
 	return cs.repo.GetOrCreate(c, userId, targetId)
 }
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	exportLinkPurpose   = "export"
+	exportLinkTTL       = 24 * time.Hour
+	exportCooldown      = time.Hour
+	exportTimeout       = 10 * time.Minute
+	exportSweepInterval = 15 * time.Minute
+	exportSweepBatch    = 100
+	// ExportDownloadPath is where the archive behind a link is served; the
+	// token goes in the query string.
+	ExportDownloadPath = "/api/v1/export/download"
+)
+
+var (
+	ErrExportCooldown    = errors.New("an export was requested recently, try again later")
+	ErrInvalidExportLink = errors.New("invalid or expired download link")
+)
+
+type exportProfile struct {
+	Id              string     `json:"id"`
+	Name            string     `json:"name"`
+	Tag             string     `json:"tag"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail    *string    `json:"pending_email"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ExportService builds archives of the personal data of a user. Archives are
+// assembled in the background and announced over the websocket with a link
+// that stays valid for exportLinkTTL. Each archive is deleted by RunExpiry
+// once its link has expired.
+type ExportService struct {
+	cTimeout         time.Duration
+	userRepo         *repository.UserRepository
+	friendRepo       *repository.FriendRepository
+	conversationRepo *repository.ConversationRepository
+	tokenRepo        *repository.TokenRepository
+	store            storage.Store
+	websocketService *WebsocketService
+	logger           *zap.Logger
+}
+
+func NewExportService(
+	cTimeout time.Duration,
+	userRepo *repository.UserRepository,
+	friendRepo *repository.FriendRepository,
+	conversationRepo *repository.ConversationRepository,
+	tokenRepo *repository.TokenRepository,
+	store storage.Store,
+	websocketService *WebsocketService,
+	logger *zap.Logger,
+) *ExportService {
+	return &ExportService{
+		cTimeout:         cTimeout,
+		userRepo:         userRepo,
+		friendRepo:       friendRepo,
+		conversationRepo: conversationRepo,
+		tokenRepo:        tokenRepo,
+		store:            store,
+		websocketService: websocketService,
+		logger:           logger,
+	}
+}
+
+// Request starts an export for the user. It returns once the job is queued;
+// the result arrives as an "export.ready" or "export.failed" websocket event.
+func (es *ExportService) Request(c context.Context, userId string) error {
+	c, cancel := context.WithTimeout(c, es.cTimeout)
+	defer cancel()
+
+	ok, err := es.tokenRepo.AcquireCooldown(c, exportLinkPurpose+":"+userId, exportCooldown)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrExportCooldown
+	}
+
+	go es.run(userId)
+	return nil
+}
+
+func (es *ExportService) run(userId string) {
+	c, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	token, expiresAt, err := es.export(c, userId)
+	if err != nil {
+		es.logger.Error("Failed to export user data", zap.Error(err), zap.String("userId", userId))
+		es.websocketService.SendToUser(userId, Message{
+			Type:   "export.failed",
+			UserID: userId,
+		})
+		return
+	}
+
+	es.websocketService.SendToUser(userId, Message{
+		Type:   "export.ready",
+		UserID: userId,
+		Data: map[string]any{
+			"url":        ExportDownloadPath + "?token=" + token,
+			"expires_at": expiresAt,
+		},
+	})
+}
+
+// export stores a new archive and returns a download token for it. The
+// archive is streamed to the store while it is being built, and its deletion
+// is scheduled before it is written so a failed export leaves nothing behind.
+func (es *ExportService) export(c context.Context, userId string) (string, time.Time, error) {
+	key := exportKey(userId, ulid.Make().String())
+	expiresAt := time.Now().UTC().Add(exportLinkTTL)
+	if err := es.tokenRepo.ScheduleExpiry(c, exportLinkPurpose, key, expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(es.build(c, pw, userId))
+	}()
+	err := es.store.Put(c, key, pr)
+	// Unblocks build if the store gave up before reading everything.
+	pr.CloseWithError(err)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err = es.tokenRepo.StoreOneTime(c, exportLinkPurpose, hashToken(token), key, exportLinkTTL); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// build writes the archive to w: one JSON document per kind of data. Lists
+// are written a page at a time, so long histories are never held in memory.
+func (es *ExportService) build(c context.Context, w io.Writer, userId string) error {
+	user, err := es.userRepo.GetById(c, userId)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	err = writeJSONDocument(zw, "profile.json", exportProfile{
+		Id:              user.Id.String(),
+		Name:            user.Name,
+		Tag:             user.Tag,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		PendingEmail:    user.PendingEmail,
+		TwoFactor:       user.TOTPEnabledAt != nil,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if err = writeJSONArray(zw, "friends.json", es.friends(c, userId)); err != nil {
+		return err
+	}
+	if err = writeJSONArray(zw, "conversations.json", es.conversations(c, userId)); err != nil {
+		return err
+	}
+	if err = writeJSONArray(zw, "messages.json", es.messages(c, userId)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeJSONDocument(zw *zip.Writer, name string, data any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// writeJSONArray writes the pages returned by next as a single JSON array,
+// formatted like writeJSONDocument would format the whole slice. next returns
+// an empty page once there is nothing left.
+func writeJSONArray[T any](zw *zip.Writer, name string, next func() ([]T, error)) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	sep := "[\n  "
+	for {
+		page, err := next()
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, item := range page {
+			data, err := json.MarshalIndent(item, "  ", "  ")
+			if err != nil {
+				return err
+			}
+			if _, err = io.WriteString(w, sep); err != nil {
+				return err
+			}
+			if _, err = w.Write(data); err != nil {
+				return err
+			}
+			sep = ",\n  "
+		}
+	}
+
+	end := "\n]\n"
+	if sep == "[\n  " {
+		end = "[]\n"
+	}
+	_, err = io.WriteString(w, end)
+	return err
+}
+
+// friends pages through the friends of the user.
+func (es *ExportService) friends(c context.Context, userId string) func() ([]entity.FriendUser, error) {
+	const limit = 100
+
+	offset, done := 0, false
+	return func() ([]entity.FriendUser, error) {
+		if done {
+			return nil, nil
+		}
+		page, err := es.friendRepo.List(c, userId, limit, offset)
+		offset += limit
+		done = len(page) < limit
+		return page, err
+	}
+}
+
+// conversations pages through every conversation of the user, including the
+// ones they hid or left.
+func (es *ExportService) conversations(c context.Context, userId string) func() ([]entity.ConversationSummary, error) {
+	const limit = 50
+
+	offset, done := 0, false
+	return func() ([]entity.ConversationSummary, error) {
+		if done {
+			return nil, nil
+		}
+		page, err := es.conversationRepo.ListWithHidden(c, userId, limit, offset)
+		offset += limit
+		done = len(page) < limit
+		return page, err
+	}
+}
+
+// messages pages through every message the user sent, whether or not the
+// conversation is still visible to them.
+func (es *ExportService) messages(c context.Context, userId string) func() ([]entity.Message, error) {
+	const limit = 500
+
+	var afterCreatedAt time.Time
+	var afterId string
+	done := false
+	return func() ([]entity.Message, error) {
+		if done {
+			return nil, nil
+		}
+		page, err := es.conversationRepo.ListSentMessages(c, userId, afterCreatedAt, afterId, limit)
+		done = len(page) < limit
+		if len(page) > 0 {
+			last := page[len(page)-1]
+			afterCreatedAt, afterId = last.CreatedAt, last.ID
+		}
+		return page, err
+	}
+}
+
+// RunExpiry deletes expired archives right away and then every
+// exportSweepInterval until c is done.
+func (es *ExportService) RunExpiry(c context.Context) {
+	ticker := time.NewTicker(exportSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := es.DeleteExpired(c)
+		if err != nil {
+			es.logger.Error("Failed to delete expired exports", zap.Error(err))
+		} else if deleted > 0 {
+			es.logger.Info("Deleted expired exports", zap.Int64("count", deleted))
+		}
+
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeleteExpired removes every archive whose download link has expired.
+// Archives that fail to delete stay scheduled and are retried by the next run.
+func (es *ExportService) DeleteExpired(c context.Context) (int64, error) {
+	before := time.Now().UTC()
+
+	var total int64
+	for {
+		batchCtx, cancel := context.WithTimeout(c, es.cTimeout)
+		deleted, due, err := es.deleteExpiredBatch(batchCtx, before)
+		cancel()
+		total += int64(deleted)
+		if err != nil || due < exportSweepBatch || deleted == 0 {
+			return total, err
+		}
+	}
+}
+
+func (es *ExportService) deleteExpiredBatch(c context.Context, before time.Time) (int, int, error) {
+	keys, err := es.tokenRepo.DueExpiries(c, exportLinkPurpose, before, exportSweepBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	deleted := make([]string, 0, len(keys))
+	var errs []error
+	for _, key := range keys {
+		if err = es.store.Delete(c, key); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, key)
+	}
+	if err = es.tokenRepo.RemoveExpiry(c, exportLinkPurpose, deleted...); err != nil {
+		return 0, len(keys), err
+	}
+	return len(deleted), len(keys), errors.Join(errs...)
+}
+
+// Open returns the archive a download token points to. The token stays valid
+// until it expires, so an interrupted download can be retried. The reader
+// outlives the call, so only the token lookup is bounded by cTimeout.
+func (es *ExportService) Open(c context.Context, token string) (io.ReadCloser, error) {
+	lookupCtx, cancel := context.WithTimeout(c, es.cTimeout)
+	defer cancel()
+
+	key, err := es.tokenRepo.PeekOneTime(lookupCtx, exportLinkPurpose, hashToken(token))
+	if err != nil {
+		return nil, ErrInvalidExportLink
+	}
+
+	archive, err := es.store.Open(c, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrInvalidExportLink
+	}
+	return archive, err
+}
+
+func exportKey(userId string, exportId string) string {
+	return "exports/" + userId + "/" + exportId + ".zip"
+}
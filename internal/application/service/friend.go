@@ -2,22 +2,66 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/neokofg/callap-backend/internal/domain/entity"
 	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"go.uber.org/zap"
 )
 
+const (
+	friendRequestSweepInterval = time.Hour
+	friendRequestSweepBatch    = 500
+)
+
+var (
+	// ErrUserBlocked refuses friend requests and messages between two users
+	// while either blocks the other.
+	ErrUserBlocked     = repository.ErrBlocked
+	ErrCannotBlockSelf = errors.New("cannot block yourself")
+)
+
+// FriendService manages friend requests and friendships. The other party of
+// every change hears about it over the websocket:
+//
+//   - "friend.request" carries the new request, as listed by GetPending;
+//   - "friend.accepted" carries the user who accepted;
+//   - "friend.removed" carries the user who declined a request, took back
+//     their own or ended the friendship.
+//
+// Requests left pending for requestTTL are removed by the sweep started by
+// RunRequestExpiry, without an event.
 type FriendService struct {
-	cTimeout time.Duration
-	repo     *repository.FriendRepository
+	cTimeout         time.Duration
+	requestTTL       time.Duration
+	repo             *repository.FriendRepository
+	userRepo         *repository.UserRepository
+	websocketService *WebsocketService
+	logger           *zap.Logger
 }
 
-func NewFriendService(cTimeout time.Duration, repo *repository.FriendRepository) *FriendService {
-	return &FriendService{
-		cTimeout: cTimeout,
-		repo:     repo,
+func NewFriendService(
+	cTimeout time.Duration,
+	requestTTL time.Duration,
+	repo *repository.FriendRepository,
+	userRepo *repository.UserRepository,
+	websocketService *WebsocketService,
+	logger *zap.Logger,
+) (*FriendService, error) {
+	// A request would be past its TTL as soon as it is sent, and every sweep
+	// would remove all pending requests.
+	if requestTTL <= 0 {
+		return nil, errors.New("friend request ttl must be positive")
 	}
+	return &FriendService{
+		cTimeout:         cTimeout,
+		requestTTL:       requestTTL,
+		repo:             repo,
+		userRepo:         userRepo,
+		websocketService: websocketService,
+		logger:           logger,
+	}, nil
 }
 
 func (fs *FriendService) List(c context.Context, userId string, limit int, offset int) ([]entity.FriendUser, error) {
@@ -31,33 +75,189 @@ func (fs *FriendService) Delete(c context.Context, userId string, friendId strin
 	c, cancel := context.WithTimeout(c, fs.cTimeout)
 	defer cancel()
 
-	return fs.repo.Delete(c, userId, friendId)
+	if err := fs.repo.Delete(c, userId, friendId); err != nil {
+		return err
+	}
+	fs.notify(c, friendId, "friend.removed", userId)
+	return nil
 }
 
 func (fs *FriendService) Decline(c context.Context, userId string, id string) error {
 	c, cancel := context.WithTimeout(c, fs.cTimeout)
 	defer cancel()
 
-	return fs.repo.Decline(c, userId, id)
+	senderId, err := fs.repo.Decline(c, userId, id)
+	if err != nil {
+		return err
+	}
+	fs.notify(c, senderId, "friend.removed", userId)
+	return nil
 }
 
 func (fs *FriendService) Accept(c context.Context, userId string, id string) error {
 	c, cancel := context.WithTimeout(c, fs.cTimeout)
 	defer cancel()
 
-	return fs.repo.Accept(c, userId, id)
+	senderId, err := fs.repo.Accept(c, userId, id)
+	if err != nil {
+		return err
+	}
+	fs.notify(c, senderId, "friend.accepted", userId)
+	return nil
+}
+
+func (fs *FriendService) GetPending(c context.Context, userId string, limit int, offset int) ([]*entity.PendingFriend, error) {
+	c, cancel := context.WithTimeout(c, fs.cTimeout)
+	defer cancel()
+
+	return fs.repo.GetPending(c, userId, limit, offset)
 }
 
-func (fs *FriendService) GetPending(c context.Context, userId string) ([]*entity.PendingFriend, error) {
+func (fs *FriendService) GetOutgoing(c context.Context, userId string, limit int, offset int) ([]entity.OutgoingFriend, error) {
 	c, cancel := context.WithTimeout(c, fs.cTimeout)
 	defer cancel()
 
-	return fs.repo.GetPending(c, userId)
+	return fs.repo.GetOutgoing(c, userId, limit, offset)
+}
+
+// Cancel takes back a request the user sent.
+func (fs *FriendService) Cancel(c context.Context, userId string, id string) error {
+	c, cancel := context.WithTimeout(c, fs.cTimeout)
+	defer cancel()
+
+	recipientId, err := fs.repo.Cancel(c, userId, id)
+	if err != nil {
+		return err
+	}
+	fs.notify(c, recipientId, "friend.removed", userId)
+	return nil
+}
+
+// RunRequestExpiry expires old requests right away and then every
+// friendRequestSweepInterval until c is done.
+func (fs *FriendService) RunRequestExpiry(c context.Context) {
+	ticker := time.NewTicker(friendRequestSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := fs.ExpireRequests(c)
+		if err != nil {
+			fs.logger.Error("Failed to expire friend requests", zap.Error(err))
+		} else if expired > 0 {
+			fs.logger.Info("Expired friend requests", zap.Int64("count", expired))
+		}
+
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireRequests removes every request that has been pending for longer
+// than requestTTL.
+func (fs *FriendService) ExpireRequests(c context.Context) (int64, error) {
+	before := time.Now().UTC().Add(-fs.requestTTL)
+
+	var total int64
+	for {
+		batchCtx, cancel := context.WithTimeout(c, fs.cTimeout)
+		expired, err := fs.repo.ExpirePending(batchCtx, before, friendRequestSweepBatch)
+		cancel()
+		total += expired
+		if err != nil || expired < friendRequestSweepBatch {
+			return total, err
+		}
+	}
 }
 
 func (fs *FriendService) AddFriend(c context.Context, userId string, friendId string) error {
 	c, cancel := context.WithTimeout(c, fs.cTimeout)
 	defer cancel()
 
-	return fs.repo.AddFriend(c, userId, friendId)
+	id, err := fs.repo.AddFriend(c, userId, friendId)
+	if err != nil {
+		return err
+	}
+
+	sender, err := fs.userRepo.GetById(c, userId)
+	if err != nil {
+		fs.logger.Error("Failed to load friend request sender", zap.Error(err), zap.String("userId", userId))
+		return nil
+	}
+	fs.websocketService.SendToUser(friendId, Message{
+		Type:   "friend.request",
+		UserID: userId,
+		Data: friendRequestEvent{
+			Id:        id,
+			SenderId:  userId,
+			Name:      sender.Name,
+			Tag:       sender.Tag,
+			AvatarURL: AvatarURL(userId, sender.AvatarId),
+		},
+	})
+	return nil
+}
+
+func (fs *FriendService) Block(c context.Context, userId string, blockedId string) error {
+	c, cancel := context.WithTimeout(c, fs.cTimeout)
+	defer cancel()
+
+	if userId == blockedId {
+		return ErrCannotBlockSelf
+	}
+	return fs.repo.Block(c, userId, blockedId)
+}
+
+func (fs *FriendService) Unblock(c context.Context, userId string, blockedId string) error {
+	c, cancel := context.WithTimeout(c, fs.cTimeout)
+	defer cancel()
+
+	return fs.repo.Unblock(c, userId, blockedId)
+}
+
+func (fs *FriendService) ListBlocked(c context.Context, userId string) ([]entity.FriendUser, error) {
+	c, cancel := context.WithTimeout(c, fs.cTimeout)
+	defer cancel()
+
+	return fs.repo.ListBlocked(c, userId)
+}
+
+// friendRequestEvent is the payload of "friend.request".
+type friendRequestEvent struct {
+	Id        string  `json:"id"`
+	SenderId  string  `json:"sender_id"`
+	Name      string  `json:"name"`
+	Tag       string  `json:"tag"`
+	AvatarURL *string `json:"avatar_url"`
+}
+
+// friendEvent is the payload of "friend.accepted" and "friend.removed".
+type friendEvent struct {
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	Tag       string  `json:"tag"`
+	AvatarURL *string `json:"avatar_url"`
+}
+
+// notify sends an event about userId to recipientId. The change is already
+// stored, so failures are only logged.
+func (fs *FriendService) notify(c context.Context, recipientId string, eventType string, userId string) {
+	user, err := fs.userRepo.GetById(c, userId)
+	if err != nil {
+		fs.logger.Error("Failed to load user for friend event", zap.Error(err), zap.String("userId", userId))
+		return
+	}
+
+	fs.websocketService.SendToUser(recipientId, Message{
+		Type:   eventType,
+		UserID: userId,
+		Data: friendEvent{
+			Id:        userId,
+			Name:      user.Name,
+			Tag:       user.Tag,
+			AvatarURL: AvatarURL(userId, user.AvatarId),
+		},
+	})
 }
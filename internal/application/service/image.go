@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MediaPath is where resized profile images are served; see
+	// imageKind.url for the layout below it.
+	MediaPath = "/api/v1/media"
+	// imageMaxSide bounds the dimensions of an upload before it is decoded,
+	// so a small file cannot expand into gigabytes of pixels.
+	imageMaxSide  = 8192
+	imageOriginal = "original"
+)
+
+var (
+	ErrInvalidImage  = errors.New("unsupported image, use PNG, JPEG, GIF or WebP")
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+	ErrImageNotFound = errors.New("image not found")
+)
+
+// imageKind describes a kind of profile image: uploads are cropped to aspect
+// (width / height) around their center and stored as WebP at each width,
+// next to the untouched original.
+type imageKind struct {
+	dir          string
+	aspect       float64
+	widths       []int
+	defaultWidth int
+}
+
+var (
+	avatarImage = imageKind{dir: "avatars", aspect: 1, widths: []int{64, 128, 512}, defaultWidth: 128}
+	bannerImage = imageKind{dir: "banners", aspect: 3, widths: []int{480, 960, 1920}, defaultWidth: 960}
+
+	imageKinds = map[string]imageKind{
+		avatarImage.dir: avatarImage,
+		bannerImage.dir: bannerImage,
+	}
+)
+
+// AvatarURL returns the URL of the default size of an avatar, or nil when the
+// user has none. The other sizes live next to it as <width>.webp.
+func AvatarURL(userId string, avatarId *string) *string {
+	return avatarImage.url(userId, avatarId)
+}
+
+// BannerURL is AvatarURL for profile banners.
+func BannerURL(userId string, bannerId *string) *string {
+	return bannerImage.url(userId, bannerId)
+}
+
+func (k imageKind) url(userId string, imageId *string) *string {
+	if imageId == nil {
+		return nil
+	}
+	url := MediaPath + "/" + k.key(userId, *imageId, variantName(k.defaultWidth))
+	return &url
+}
+
+func (k imageKind) key(userId string, imageId string, variant string) string {
+	return k.dir + "/" + userId + "/" + imageId + "/" + variant
+}
+
+func (k imageKind) hasVariant(variant string) bool {
+	for _, width := range k.widths {
+		if variant == variantName(width) {
+			return true
+		}
+	}
+	return false
+}
+
+func variantName(width int) string {
+	return strconv.Itoa(width) + ".webp"
+}
+
+func decodeImage(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if config.Width > imageMaxSide || config.Height > imageMaxSide {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return img, nil
+}
+
+// render crops img to the aspect of the kind and scales it to width.
+func (k imageKind) render(img image.Image, width int) image.Image {
+	src := img.Bounds()
+	crop := src
+	if float64(src.Dx())/float64(src.Dy()) > k.aspect {
+		w := max(1, int(float64(src.Dy())*k.aspect))
+		x := src.Min.X + (src.Dx()-w)/2
+		crop = image.Rect(x, src.Min.Y, x+w, src.Max.Y)
+	} else {
+		h := max(1, int(float64(src.Dx())/k.aspect))
+		y := src.Min.Y + (src.Dy()-h)/2
+		crop = image.Rect(src.Min.X, y, src.Max.X, y+h)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, max(1, int(float64(width)/k.aspect))))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+// storeImage writes the original upload and every variant of it under
+// imageId. Resizing does not watch c, so it is checked between variants.
+func storeImage(c context.Context, store storage.Store, kind imageKind, userId string, imageId string, data []byte) error {
+	img, err := decodeImage(data)
+	if err != nil {
+		return err
+	}
+
+	if err = store.Put(c, kind.key(userId, imageId, imageOriginal), bytes.NewReader(data)); err != nil {
+		return err
+	}
+	for _, width := range kind.widths {
+		if err = c.Err(); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err = nativewebp.Encode(&buf, kind.render(img, width), nil); err != nil {
+			return err
+		}
+		if err = store.Put(c, kind.key(userId, imageId, variantName(width)), &buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteImage removes the original and the variants of an image. A nil id is
+// a no-op.
+func deleteImage(c context.Context, store storage.Store, kind imageKind, userId string, imageId *string) error {
+	if imageId == nil {
+		return nil
+	}
+
+	errs := []error{store.Delete(c, kind.key(userId, *imageId, imageOriginal))}
+	for _, width := range kind.widths {
+		errs = append(errs, store.Delete(c, kind.key(userId, *imageId, variantName(width))))
+	}
+	return errors.Join(errs...)
+}
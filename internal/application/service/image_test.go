@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
+)
+
+// memoryStore is an in-memory storage.Store standing in for S3 or MinIO.
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: map[string][]byte{}}
+}
+
+func (s *memoryStore) Put(c context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memoryStore) Open(c context.Context, key string) (io.ReadCloser, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStore) Delete(c context.Context, key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// bandImage is white with a red band in the middle third along its longer
+// side, so a centered crop keeps red at the center of the result.
+func bandImage(width int, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			if width >= height && x >= width/3 && x < 2*width/3 ||
+				width < height && y >= height/3 && y < 2*height/3 {
+				c = color.NRGBA{R: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestImageKindRenderSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		kind          imageKind
+		width, height int
+	}{
+		{name: "avatar from wide", kind: avatarImage, width: 300, height: 100},
+		{name: "avatar from tall", kind: avatarImage, width: 100, height: 300},
+		{name: "avatar from tiny", kind: avatarImage, width: 1, height: 1},
+		{name: "banner from square", kind: bannerImage, width: 200, height: 200},
+		{name: "banner from wide", kind: bannerImage, width: 1000, height: 100},
+		{name: "banner from thin", kind: bannerImage, width: 2, height: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := bandImage(tt.width, tt.height)
+			for _, width := range tt.kind.widths {
+				got := tt.kind.render(src, width).Bounds()
+				want := image.Rect(0, 0, width, int(float64(width)/tt.kind.aspect))
+				if got != want {
+					t.Errorf("render(%dx%d, %d) = %v, want %v", tt.width, tt.height, width, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImageKindRenderCropsCenter(t *testing.T) {
+	tests := []struct {
+		name          string
+		kind          imageKind
+		width, height int
+	}{
+		{name: "avatar from wide", kind: avatarImage, width: 600, height: 200},
+		{name: "avatar from tall", kind: avatarImage, width: 200, height: 600},
+		{name: "banner from tall", kind: bannerImage, width: 300, height: 900},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := tt.kind.render(bandImage(tt.width, tt.height), tt.kind.widths[0])
+			b := dst.Bounds()
+
+			r, g, _, _ := dst.At(b.Dx()/2, b.Dy()/2).RGBA()
+			if r>>8 != 255 || g>>8 > 16 {
+				t.Errorf("center pixel is not red, the crop is off center")
+			}
+			if tt.width > tt.height {
+				// The crop is exactly the red band of a 3:1 source.
+				_, g, _, _ = dst.At(0, b.Dy()/2).RGBA()
+				if g>>8 > 64 {
+					t.Errorf("left edge is white, the crop is wider than the center")
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeImage(t *testing.T) {
+	if _, err := decodeImage([]byte("not an image")); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("decodeImage(garbage) = %v, want ErrInvalidImage", err)
+	}
+
+	large := encodePNG(t, image.NewGray(image.Rect(0, 0, imageMaxSide+1, 1)))
+	if _, err := decodeImage(large); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("decodeImage(too wide) = %v, want ErrImageTooLarge", err)
+	}
+
+	img, err := decodeImage(encodePNG(t, bandImage(30, 20)))
+	if err != nil {
+		t.Fatalf("decodeImage: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(30, 20) {
+		t.Errorf("decoded size = %v, want 30x20", got)
+	}
+}
+
+func TestStoreImage(t *testing.T) {
+	store := newMemoryStore()
+	data := encodePNG(t, bandImage(900, 300))
+
+	if err := storeImage(context.Background(), store, bannerImage, "user", "image", data); err != nil {
+		t.Fatalf("storeImage: %v", err)
+	}
+
+	if got := store.objects[bannerImage.key("user", "image", imageOriginal)]; !bytes.Equal(got, data) {
+		t.Errorf("original was not stored unchanged")
+	}
+	for _, width := range bannerImage.widths {
+		key := bannerImage.key("user", "image", variantName(width))
+		r, err := store.Open(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", key, err)
+		}
+		config, format, err := image.DecodeConfig(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("decode %q: %v", key, err)
+		}
+		if format != "webp" || config.Width != width || config.Height != width/3 {
+			t.Errorf("%q is %s %dx%d, want webp %dx%d", key, format, config.Width, config.Height, width, width/3)
+		}
+	}
+	if want := len(bannerImage.widths) + 1; len(store.objects) != want {
+		t.Errorf("stored %d objects, want %d", len(store.objects), want)
+	}
+
+	imageId := "image"
+	if err := deleteImage(context.Background(), store, bannerImage, "user", &imageId); err != nil {
+		t.Fatalf("deleteImage: %v", err)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("deleteImage left %d objects", len(store.objects))
+	}
+}
+
+func TestStoreImageStopsWhenCanceled(t *testing.T) {
+	store := newMemoryStore()
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := storeImage(c, store, avatarImage, "user", "image", encodePNG(t, bandImage(10, 10)))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("storeImage = %v, want context.Canceled", err)
+	}
+	for key := range store.objects {
+		if key != avatarImage.key("user", "image", imageOriginal) {
+			t.Errorf("variant %q was stored after cancel", key)
+		}
+	}
+}
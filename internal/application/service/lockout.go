@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/internal/infrastructure/mail"
+)
+
+// LockoutNotifier tells account owners that their login was locked, both by
+// email and over an open websocket, so they can react to a guessing attack.
+type LockoutNotifier struct {
+	userRepo         *repository.UserRepository
+	mailService      *MailService
+	websocketService *WebsocketService
+}
+
+func NewLockoutNotifier(userRepo *repository.UserRepository, mailService *MailService, websocketService *WebsocketService) *LockoutNotifier {
+	return &LockoutNotifier{
+		userRepo:         userRepo,
+		mailService:      mailService,
+		websocketService: websocketService,
+	}
+}
+
+func (ln *LockoutNotifier) Notify(c context.Context, email string, until time.Time) {
+	user, err := ln.userRepo.GetByEmail(c, email)
+	if err != nil {
+		return
+	}
+
+	ln.mailService.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Too many failed login attempts",
+		Body: fmt.Sprintf(
+			"We noticed several failed attempts to log in to your Callap account, so logging in is blocked until %s.\n\nIf it was not you, consider changing your password.",
+			until.UTC().Format(time.RFC1123),
+		),
+	})
+
+	ln.websocketService.SendToUser(user.Id.String(), Message{
+		Type:   "account.lockout",
+		UserID: user.Id.String(),
+		Data: map[string]any{
+			"until": until.UTC(),
+		},
+	})
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+)
+
+const (
+	loginFailureWindow  = 15 * time.Minute
+	loginEmailThreshold = 5
+	loginIpThreshold    = 20
+	loginLockBase       = 30 * time.Second
+	loginLockMax        = time.Hour
+)
+
+// LockoutHook is called once an email gets locked because of failed logins.
+type LockoutHook func(c context.Context, email string, until time.Time)
+
+type LoginThrottleService struct {
+	cTimeout time.Duration
+	repo     *repository.LoginAttemptRepository
+	hooks    []LockoutHook
+}
+
+func NewLoginThrottleService(cTimeout time.Duration, repo *repository.LoginAttemptRepository) *LoginThrottleService {
+	return &LoginThrottleService{
+		cTimeout: cTimeout,
+		repo:     repo,
+	}
+}
+
+func (lts *LoginThrottleService) OnLockout(hook LockoutHook) {
+	lts.hooks = append(lts.hooks, hook)
+}
+
+// LockedFor returns the remaining lockout of the email or the IP, whichever
+// is longer. Zero means the login attempt may proceed.
+func (lts *LoginThrottleService) LockedFor(c context.Context, email string, ip string) (time.Duration, error) {
+	c, cancel := context.WithTimeout(c, lts.cTimeout)
+	defer cancel()
+
+	emailLock, err := lts.repo.LockedFor(c, emailKey(email))
+	if err != nil {
+		return 0, err
+	}
+	ipLock, err := lts.repo.LockedFor(c, ipKey(ip))
+	if err != nil {
+		return 0, err
+	}
+	return max(emailLock, ipLock), nil
+}
+
+// RegisterFailure records a failed login and locks the email and/or IP once
+// their thresholds are crossed. Every further failure doubles the lockout.
+func (lts *LoginThrottleService) RegisterFailure(c context.Context, email string, ip string) (time.Duration, error) {
+	c, cancel := context.WithTimeout(c, lts.cTimeout)
+	defer cancel()
+
+	emailFailures, err := lts.repo.RegisterFailure(c, emailKey(email), loginFailureWindow)
+	if err != nil {
+		return 0, err
+	}
+	ipFailures, err := lts.repo.RegisterFailure(c, ipKey(ip), loginFailureWindow)
+	if err != nil {
+		return 0, err
+	}
+
+	var lockedFor time.Duration
+	if emailFailures >= loginEmailThreshold {
+		lockedFor = lockDuration(emailFailures - loginEmailThreshold)
+		if err = lts.repo.Lock(c, emailKey(email), lockedFor); err != nil {
+			return 0, err
+		}
+		until := time.Now().Add(lockedFor)
+		for _, hook := range lts.hooks {
+			hook(c, email, until)
+		}
+	}
+	if ipFailures >= loginIpThreshold {
+		ipLock := lockDuration(ipFailures - loginIpThreshold)
+		if err = lts.repo.Lock(c, ipKey(ip), ipLock); err != nil {
+			return 0, err
+		}
+		lockedFor = max(lockedFor, ipLock)
+	}
+	return lockedFor, nil
+}
+
+// RegisterSuccess clears the email counters. IP counters are kept so that an
+// attacker owning one valid account cannot reset them by logging in.
+func (lts *LoginThrottleService) RegisterSuccess(c context.Context, email string) error {
+	c, cancel := context.WithTimeout(c, lts.cTimeout)
+	defer cancel()
+
+	return lts.repo.Reset(c, emailKey(email))
+}
+
+func lockDuration(excess int64) time.Duration {
+	if excess > 16 {
+		return loginLockMax
+	}
+	d := loginLockBase * time.Duration(math.Pow(2, float64(excess)))
+	return min(d, loginLockMax)
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+)
+
+const (
+	magicLinkPurpose   = "magic_link"
+	magicLinkTTL       = 15 * time.Minute
+	magicLinkCooldown  = time.Minute
+	magicLinkWindow    = time.Hour
+	magicLinkPerWindow = 5
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired login link")
+
+type MagicLinkService struct {
+	cTimeout    time.Duration
+	userRepo    *repository.UserRepository
+	tokenRepo   *repository.TokenRepository
+	mailService *MailService
+}
+
+func NewMagicLinkService(
+	cTimeout time.Duration,
+	userRepo *repository.UserRepository,
+	tokenRepo *repository.TokenRepository,
+	mailService *MailService,
+) *MagicLinkService {
+	return &MagicLinkService{
+		cTimeout:    cTimeout,
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		mailService: mailService,
+	}
+}
+
+// SendLink mails a one-time login link. Issuance is limited per email to one
+// link a minute and a few an hour; over the limit nothing is sent. As with
+// password resets, callers must not reveal the outcome to the client.
+func (mls *MagicLinkService) SendLink(c context.Context, user entity.User) error {
+	c, cancel := context.WithTimeout(c, mls.cTimeout)
+	defer cancel()
+
+	email := strings.ToLower(user.Email)
+	ok, err := mls.tokenRepo.AcquireCooldown(c, magicLinkPurpose+":"+email, magicLinkCooldown)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	count, err := mls.tokenRepo.CountAttempt(c, magicLinkPurpose+":"+email, magicLinkWindow)
+	if err != nil {
+		return err
+	}
+	if count > magicLinkPerWindow {
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	value := user.Id.String() + "|" + user.Email
+	if err = mls.tokenRepo.StoreOneTime(c, magicLinkPurpose, hashToken(token), value, magicLinkTTL); err != nil {
+		return err
+	}
+
+	mls.mailService.SendMagicLink(user.Email, token)
+
+	return nil
+}
+
+// ConsumeLink resolves a login link to its user. Opening the link proves
+// control of the mailbox, so the email is marked verified along the way.
+func (mls *MagicLinkService) ConsumeLink(c context.Context, token string) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, mls.cTimeout)
+	defer cancel()
+
+	value, err := mls.tokenRepo.ConsumeOneTime(c, magicLinkPurpose, hashToken(token))
+	if err != nil {
+		return entity.User{}, ErrInvalidMagicLink
+	}
+
+	userId, email, found := strings.Cut(value, "|")
+	if !found {
+		return entity.User{}, ErrInvalidMagicLink
+	}
+
+	user, err := mls.userRepo.GetById(c, userId)
+	if err != nil {
+		return entity.User{}, ErrInvalidMagicLink
+	}
+	// The address changed after the link was sent, so it no longer proves
+	// anything about the account.
+	if user.Email != email {
+		return entity.User{}, ErrInvalidMagicLink
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err = mls.userRepo.MarkEmailVerified(c, userId); err != nil {
+			return entity.User{}, err
+		}
+		now := time.Now().UTC()
+		user.EmailVerifiedAt = &now
+	}
+
+	return user, nil
+}
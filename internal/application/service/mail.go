@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/infrastructure/mail"
+	"go.uber.org/zap"
+)
+
+type MailService struct {
+	cTimeout    time.Duration
+	mailer      mail.Mailer
+	frontendURL string
+	logger      *zap.Logger
+}
+
+func NewMailService(cTimeout time.Duration, mailer mail.Mailer, frontendURL string, logger *zap.Logger) *MailService {
+	return &MailService{
+		cTimeout:    cTimeout,
+		mailer:      mailer,
+		frontendURL: frontendURL,
+		logger:      logger,
+	}
+}
+
+// Send delivers the message in the background so slow mail servers never
+// hold up the request that triggered it.
+func (ms *MailService) Send(msg mail.Message) {
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), ms.cTimeout)
+		defer cancel()
+
+		if err := ms.mailer.Send(c, msg); err != nil {
+			ms.logger.Error("Failed to send mail", zap.Error(err), zap.String("to", msg.To), zap.String("subject", msg.Subject))
+		}
+	}()
+}
+
+func (ms *MailService) SendEmailVerification(email string, token string) {
+	ms.Send(mail.Message{
+		To:      email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Welcome to Callap!\n\nConfirm your email address by opening the link below:\n\n%s\n\nIf you did not create an account, ignore this message.",
+			ms.link("/verify-email", token),
+		),
+	})
+}
+
+func (ms *MailService) link(path string, token string) string {
+	return ms.frontendURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (ms *MailService) SendPasswordReset(email string, token string) {
+	ms.Send(mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your Callap account.\n\nOpen the link below within an hour to choose a new password:\n\n%s\n\nIf it was not you, ignore this message; your password stays the same.",
+			ms.link("/reset-password", token),
+		),
+	})
+}
+
+func (ms *MailService) SendMagicLink(email string, token string) {
+	ms.Send(mail.Message{
+		To:      email,
+		Subject: "Your Callap login link",
+		Body: fmt.Sprintf(
+			"Open the link below within 15 minutes to log in to Callap. It works only once.\n\n%s\n\nIf you did not ask for it, ignore this message.",
+			ms.link("/magic-login", token),
+		),
+	})
+}
+
+func (ms *MailService) SendAccountDeletionScheduled(email string, purgeAt time.Time) {
+	ms.Send(mail.Message{
+		To:      email,
+		Subject: "Your Callap account will be deleted",
+		Body: fmt.Sprintf(
+			"Your Callap account is scheduled for deletion and will be removed for good on %s.\n\nChanged your mind? Log in before then and the deletion is cancelled.",
+			purgeAt.UTC().Format(time.RFC1123),
+		),
+	})
+}
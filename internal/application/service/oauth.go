@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neokofg/callap-backend/internal/application/config"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/pkg/oauth"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	oauthStatePurpose = "oauth_state"
+	oauthStateTTL     = 10 * time.Minute
+)
+
+var (
+	ErrUnknownOAuthProvider  = errors.New("unknown oauth provider")
+	ErrInvalidOAuthState     = errors.New("invalid or expired oauth state")
+	ErrOAuthExchange         = errors.New("failed to authenticate with provider")
+	ErrOAuthEmailNotVerified = errors.New("provider account has no verified email")
+	ErrOAuthEmailTaken       = errors.New("an account with this email already exists, log in and link the provider instead")
+	ErrIdentityLinked        = errors.New("this provider account is linked to another user")
+	ErrLastLoginMethod       = errors.New("cannot unlink the only way to log in")
+)
+
+type oauthState struct {
+	Provider   string `json:"provider"`
+	Verifier   string `json:"verifier"`
+	NonceHash  string `json:"nonce_hash"`
+	UserId     string `json:"user_id,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+// OAuthResult describes what a completed provider callback did: either sign
+// User in (possibly creating the account) or link the identity to them.
+type OAuthResult struct {
+	User       entity.User
+	DeviceName string
+	Created    bool
+	Linked     bool
+}
+
+// The repositories used by OAuthService, narrowed so the flow can be tested
+// against a mock provider without a database.
+type (
+	oauthUserRepository interface {
+		tagFinder
+		GetById(c context.Context, id string) (entity.User, error)
+		GetByEmail(c context.Context, email string) (entity.User, error)
+	}
+	oauthIdentityRepository interface {
+		GetByProviderSubject(c context.Context, provider string, subject string) (entity.Identity, error)
+		List(c context.Context, userId string) ([]entity.Identity, error)
+		Create(c context.Context, identity entity.Identity) (entity.Identity, error)
+		CreateWithUser(c context.Context, user entity.User, identity entity.Identity) (entity.User, error)
+		Count(c context.Context, userId string) (int, error)
+		Delete(c context.Context, userId string, id string) error
+	}
+	oauthTokenRepository interface {
+		StoreOneTime(c context.Context, purpose string, hash string, value string, ttl time.Duration) error
+		ConsumeOneTime(c context.Context, purpose string, hash string) (string, error)
+	}
+)
+
+type OAuthService struct {
+	cTimeout     time.Duration
+	providers    map[string]oauth.Provider
+	userRepo     oauthUserRepository
+	identityRepo oauthIdentityRepository
+	tokenRepo    oauthTokenRepository
+}
+
+func NewOAuthService(
+	cTimeout time.Duration,
+	providers []oauth.Provider,
+	userRepo oauthUserRepository,
+	identityRepo oauthIdentityRepository,
+	tokenRepo oauthTokenRepository,
+) *OAuthService {
+	byName := make(map[string]oauth.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
+	return &OAuthService{
+		cTimeout:     cTimeout,
+		providers:    byName,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		tokenRepo:    tokenRepo,
+	}
+}
+
+// NewOAuthProviders builds a provider for every entry of the config that has a
+// client id; the others stay disabled.
+func NewOAuthProviders(cfg config.OAuth) []oauth.Provider {
+	providerConfig := func(name string, provider config.OAuthProvider) oauth.Config {
+		return oauth.Config{
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  strings.TrimSuffix(cfg.RedirectURL, "/") + "/" + name,
+		}
+	}
+
+	var providers []oauth.Provider
+	if cfg.Google.ClientID != "" {
+		providers = append(providers, oauth.NewGoogleProvider(providerConfig(oauth.ProviderGoogle, cfg.Google)))
+	}
+	if cfg.GitHub.ClientID != "" {
+		providers = append(providers, oauth.NewGitHubProvider(providerConfig(oauth.ProviderGitHub, cfg.GitHub)))
+	}
+	if cfg.OIDC.ClientID != "" {
+		providers = append(providers, oauth.NewOIDCProvider(oauth.ProviderOIDC, cfg.OIDC.Issuer, providerConfig(oauth.ProviderOIDC, cfg.OIDC)))
+	}
+	return providers
+}
+
+func (oas *OAuthService) Providers() []string {
+	names := make([]string, 0, len(oas.providers))
+	for name := range oas.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start begins the authorization code flow and returns the provider URL the
+// client has to visit, along with a nonce the client keeps to itself and sends
+// back with the callback. A non-empty userId links the identity to that user
+// instead of logging in.
+func (oas *OAuthService) Start(c context.Context, providerName string, userId string, deviceName string) (string, string, error) {
+	c, cancel := context.WithTimeout(c, oas.cTimeout)
+	defer cancel()
+
+	provider, ok := oas.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownOAuthProvider
+	}
+
+	state, err := generateToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := generateToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := generateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	value, err := json.Marshal(oauthState{
+		Provider:   providerName,
+		Verifier:   verifier,
+		NonceHash:  hashToken(nonce),
+		UserId:     userId,
+		DeviceName: deviceName,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if err = oas.tokenRepo.StoreOneTime(c, oauthStatePurpose, hashToken(state), string(value), oauthStateTTL); err != nil {
+		return "", "", err
+	}
+
+	url, err := provider.AuthCodeURL(c, state, oauth.CodeChallenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+	return url, nonce, nil
+}
+
+// Callback completes the flow started by Start. The state is single use, so
+// a replayed callback fails even if the provider would accept the code again.
+// The nonce binds the state to the client that started the flow, and userId
+// must be the user who started it: empty for a login, the caller for a link.
+// Otherwise a provider URL started by someone else could log the client into
+// a foreign account or link the client's provider account to it.
+func (oas *OAuthService) Callback(c context.Context, providerName string, userId string, code string, state string, nonce string) (OAuthResult, error) {
+	c, cancel := context.WithTimeout(c, oas.cTimeout)
+	defer cancel()
+
+	provider, ok := oas.providers[providerName]
+	if !ok {
+		return OAuthResult{}, ErrUnknownOAuthProvider
+	}
+
+	value, err := oas.tokenRepo.ConsumeOneTime(c, oauthStatePurpose, hashToken(state))
+	if err != nil {
+		return OAuthResult{}, ErrInvalidOAuthState
+	}
+	var st oauthState
+	if err = json.Unmarshal([]byte(value), &st); err != nil || st.Provider != providerName || st.UserId != userId {
+		return OAuthResult{}, ErrInvalidOAuthState
+	}
+	if subtle.ConstantTimeCompare([]byte(st.NonceHash), []byte(hashToken(nonce))) != 1 {
+		return OAuthResult{}, ErrInvalidOAuthState
+	}
+
+	profile, err := provider.Exchange(c, code, st.Verifier)
+	if err != nil {
+		return OAuthResult{}, fmt.Errorf("%w: %w", ErrOAuthExchange, err)
+	}
+
+	identity, err := oas.identityRepo.GetByProviderSubject(c, providerName, profile.Subject)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return OAuthResult{}, err
+	}
+	found := err == nil
+
+	if st.UserId != "" {
+		return oas.link(c, st, identity, found, profile)
+	}
+
+	if found {
+		user, err := oas.userRepo.GetById(c, identity.UserId.String())
+		if err != nil {
+			return OAuthResult{}, err
+		}
+		return OAuthResult{User: user, DeviceName: st.DeviceName}, nil
+	}
+
+	user, err := oas.register(c, providerName, profile)
+	if err != nil {
+		return OAuthResult{}, err
+	}
+	return OAuthResult{User: user, DeviceName: st.DeviceName, Created: true}, nil
+}
+
+func (oas *OAuthService) link(c context.Context, st oauthState, identity entity.Identity, found bool, profile oauth.Profile) (OAuthResult, error) {
+	user, err := oas.userRepo.GetById(c, st.UserId)
+	if err != nil {
+		return OAuthResult{}, err
+	}
+
+	if found {
+		if identity.UserId != user.Id {
+			return OAuthResult{}, ErrIdentityLinked
+		}
+		return OAuthResult{User: user, Linked: true}, nil
+	}
+
+	_, err = oas.identityRepo.Create(c, entity.Identity{
+		UserId:   user.Id,
+		Provider: st.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	})
+	if repository.IsUniqueViolation(err, repository.IdentitiesProviderSubjectConstraint) {
+		return OAuthResult{}, ErrIdentityLinked
+	}
+	if err != nil {
+		return OAuthResult{}, err
+	}
+	return OAuthResult{User: user, Linked: true}, nil
+}
+
+// register creates an account for a first time provider login. Accounts are
+// only matched by identity, never by email: merging into an existing account
+// requires its owner to log in and link the provider explicitly.
+func (oas *OAuthService) register(c context.Context, providerName string, profile oauth.Profile) (entity.User, error) {
+	if profile.Email == "" || !profile.EmailVerified {
+		return entity.User{}, ErrOAuthEmailNotVerified
+	}
+
+	if _, err := oas.userRepo.GetByEmail(c, profile.Email); err == nil {
+		return entity.User{}, ErrOAuthEmailTaken
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return entity.User{}, err
+	}
+
+	verifiedAt := time.Now().UTC()
+	// The password stays empty: it matches no hash format, so password login
+	// is impossible until the user sets one through the reset flow.
+	user := entity.User{
+		Id:              ulid.Make(),
+		Name:            oauthUserName(profile),
+		Email:           profile.Email,
+		EmailVerifiedAt: &verifiedAt,
+	}
+	identity := entity.Identity{
+		Provider: providerName,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+
+	var created entity.User
+	err := withRandomTag(c, oas.userRepo, user.Name, func(tag string) error {
+		user.Tag = tag
+		var err error
+		created, err = oas.identityRepo.CreateWithUser(c, user, identity)
+		return err
+	})
+	if repository.IsUniqueViolation(err, repository.UsersEmailConstraint) {
+		return entity.User{}, ErrOAuthEmailTaken
+	}
+	if err != nil {
+		return entity.User{}, err
+	}
+	return created, nil
+}
+
+func (oas *OAuthService) ListIdentities(c context.Context, userId string) ([]entity.Identity, error) {
+	c, cancel := context.WithTimeout(c, oas.cTimeout)
+	defer cancel()
+
+	return oas.identityRepo.List(c, userId)
+}
+
+func (oas *OAuthService) Unlink(c context.Context, user entity.User, id string) error {
+	c, cancel := context.WithTimeout(c, oas.cTimeout)
+	defer cancel()
+
+	if user.Password == "" {
+		count, err := oas.identityRepo.Count(c, user.Id.String())
+		if err != nil {
+			return err
+		}
+		if count <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+
+	return oas.identityRepo.Delete(c, user.Id.String(), id)
+}
+
+// oauthUserName fits the provider display name into the 3-32 characters
+// allowed at registration, falling back to the email local part.
+func oauthUserName(profile oauth.Profile) string {
+	for _, candidate := range []string{profile.Name, strings.SplitN(profile.Email, "@", 2)[0]} {
+		name := strings.TrimSpace(candidate)
+		if utf8.RuneCountInString(name) > 32 {
+			name = strings.TrimSpace(string([]rune(name)[:32]))
+		}
+		if utf8.RuneCountInString(name) >= 3 {
+			return name
+		}
+	}
+	return "user"
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/pkg/oauth"
+	"github.com/neokofg/callap-backend/pkg/oauth/oauthtest"
+	"github.com/oklog/ulid/v2"
+)
+
+// memoryTokens keeps one-time tokens in memory in place of Redis.
+type memoryTokens struct {
+	values map[string]string
+}
+
+func newMemoryTokens() *memoryTokens {
+	return &memoryTokens{values: map[string]string{}}
+}
+
+func (m *memoryTokens) StoreOneTime(c context.Context, purpose string, hash string, value string, ttl time.Duration) error {
+	m.values[purpose+":"+hash] = value
+	return nil
+}
+
+func (m *memoryTokens) ConsumeOneTime(c context.Context, purpose string, hash string) (string, error) {
+	value, ok := m.values[purpose+":"+hash]
+	if !ok {
+		return "", errors.New("token not found or expired")
+	}
+	delete(m.values, purpose+":"+hash)
+	return value, nil
+}
+
+// memoryAccounts stores users and identities in memory in place of Postgres.
+type memoryAccounts struct {
+	users      map[string]entity.User
+	identities []entity.Identity
+}
+
+func newMemoryAccounts() *memoryAccounts {
+	return &memoryAccounts{users: map[string]entity.User{}}
+}
+
+func (m *memoryAccounts) addUser(name string, email string) entity.User {
+	user := entity.NewUser(entity.User{Name: name, Tag: "aaa", Email: email, Password: "hash"})
+	m.users[user.Id.String()] = user
+	return user
+}
+
+func (m *memoryAccounts) GetById(c context.Context, id string) (entity.User, error) {
+	user, ok := m.users[id]
+	if !ok {
+		return entity.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *memoryAccounts) GetByEmail(c context.Context, email string) (entity.User, error) {
+	for _, user := range m.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return entity.User{}, pgx.ErrNoRows
+}
+
+func (m *memoryAccounts) FirstFreeTag(c context.Context, name string, alphabet string) (string, error) {
+	return "", nil
+}
+
+func (m *memoryAccounts) GetByProviderSubject(c context.Context, provider string, subject string) (entity.Identity, error) {
+	for _, identity := range m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return entity.Identity{}, pgx.ErrNoRows
+}
+
+func (m *memoryAccounts) List(c context.Context, userId string) ([]entity.Identity, error) {
+	var identities []entity.Identity
+	for _, identity := range m.identities {
+		if identity.UserId.String() == userId {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (m *memoryAccounts) Create(c context.Context, identity entity.Identity) (entity.Identity, error) {
+	identity = entity.NewIdentity(identity)
+	m.identities = append(m.identities, identity)
+	return identity, nil
+}
+
+func (m *memoryAccounts) CreateWithUser(c context.Context, user entity.User, identity entity.Identity) (entity.User, error) {
+	user = entity.NewUser(user)
+	m.users[user.Id.String()] = user
+	identity.UserId = user.Id
+	_, err := m.Create(c, identity)
+	return user, err
+}
+
+func (m *memoryAccounts) Count(c context.Context, userId string) (int, error) {
+	identities, err := m.List(c, userId)
+	return len(identities), err
+}
+
+func (m *memoryAccounts) Delete(c context.Context, userId string, id string) error {
+	for i, identity := range m.identities {
+		if identity.UserId.String() == userId && identity.Id.String() == id {
+			m.identities = append(m.identities[:i], m.identities[i+1:]...)
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+type oauthTest struct {
+	server   *oauthtest.Server
+	accounts *memoryAccounts
+	service  *OAuthService
+}
+
+func newOAuthTest(t *testing.T) *oauthTest {
+	t.Helper()
+	server, err := oauthtest.NewServer()
+	if err != nil {
+		t.Fatalf("oauthtest.NewServer: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	provider := oauth.NewOIDCProvider(oauth.ProviderOIDC, server.URL, oauthtest.Config("http://localhost:3000/oauth/callback/oidc"))
+	accounts := newMemoryAccounts()
+	return &oauthTest{
+		server:   server,
+		accounts: accounts,
+		service:  NewOAuthService(5*time.Second, []oauth.Provider{provider}, accounts, accounts, newMemoryTokens()),
+	}
+}
+
+type oauthFlow struct {
+	code  string
+	state string
+	nonce string
+}
+
+// start begins a flow for userId and approves it at the provider as user.
+func (ot *oauthTest) start(t *testing.T, userId string, user oauthtest.User) oauthFlow {
+	t.Helper()
+	authURL, nonce, err := ot.service.Start(context.Background(), oauth.ProviderOIDC, userId, "phone")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	code, state, err := ot.server.Authorize(authURL, user)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return oauthFlow{code: code, state: state, nonce: nonce}
+}
+
+func (ot *oauthTest) callback(userId string, flow oauthFlow) (OAuthResult, error) {
+	return ot.service.Callback(context.Background(), oauth.ProviderOIDC, userId, flow.code, flow.state, flow.nonce)
+}
+
+var alice = oauthtest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+func TestOAuthLogin(t *testing.T) {
+	ot := newOAuthTest(t)
+
+	result, err := ot.callback("", ot.start(t, "", alice))
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if !result.Created || result.User.Email != alice.Email || result.User.Name != alice.Name || result.DeviceName != "phone" {
+		t.Fatalf("first login = %+v, want a new account for %s", result, alice.Email)
+	}
+	if result.User.EmailVerifiedAt == nil {
+		t.Errorf("account created from a verified provider email is not verified")
+	}
+
+	again, err := ot.callback("", ot.start(t, "", alice))
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.Created || again.User.Id != result.User.Id {
+		t.Errorf("second login = %+v, want the account %s", again, result.User.Id)
+	}
+}
+
+func TestOAuthLoginRequiresVerifiedEmail(t *testing.T) {
+	ot := newOAuthTest(t)
+
+	for _, user := range []oauthtest.User{
+		{Subject: "unverified", Email: "bob@example.com", EmailVerified: false, Name: "Bob"},
+		{Subject: "no-email", EmailVerified: true, Name: "Bob"},
+	} {
+		if _, err := ot.callback("", ot.start(t, "", user)); !errors.Is(err, ErrOAuthEmailNotVerified) {
+			t.Errorf("login of %q = %v, want ErrOAuthEmailNotVerified", user.Subject, err)
+		}
+	}
+	if len(ot.accounts.users) != 0 {
+		t.Errorf("%d accounts were created", len(ot.accounts.users))
+	}
+}
+
+func TestOAuthLoginDoesNotMergeByEmail(t *testing.T) {
+	ot := newOAuthTest(t)
+	ot.accounts.addUser("Alice", alice.Email)
+
+	if _, err := ot.callback("", ot.start(t, "", alice)); !errors.Is(err, ErrOAuthEmailTaken) {
+		t.Errorf("login with the email of another account = %v, want ErrOAuthEmailTaken", err)
+	}
+}
+
+func TestOAuthCallbackRejectsReplayedState(t *testing.T) {
+	ot := newOAuthTest(t)
+
+	flow := ot.start(t, "", alice)
+	if _, err := ot.callback("", flow); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	// A fresh code for the same state, so only the state can be refused.
+	replay := ot.start(t, "", alice)
+	replay.state, replay.nonce = flow.state, flow.nonce
+	if _, err := ot.callback("", replay); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("replayed state = %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestOAuthCallbackRequiresNonce(t *testing.T) {
+	ot := newOAuthTest(t)
+
+	flow := ot.start(t, "", alice)
+	stolen := flow
+	stolen.nonce = "guessed"
+	if _, err := ot.callback("", stolen); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("callback with a wrong nonce = %v, want ErrInvalidOAuthState", err)
+	}
+	if _, err := ot.callback("", flow); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("callback after a failed attempt = %v, want ErrInvalidOAuthState", err)
+	}
+	if len(ot.accounts.users) != 0 {
+		t.Errorf("%d accounts were created", len(ot.accounts.users))
+	}
+}
+
+func TestOAuthCallbackRequiresPKCEVerifierOfTheFlow(t *testing.T) {
+	ot := newOAuthTest(t)
+
+	// A code issued for another flow is bound to that flow's code challenge.
+	first := ot.start(t, "", alice)
+	second := ot.start(t, "", alice)
+	first.code = second.code
+	if _, err := ot.callback("", first); !errors.Is(err, ErrOAuthExchange) {
+		t.Errorf("callback with a code of another flow = %v, want ErrOAuthExchange", err)
+	}
+}
+
+func TestOAuthLink(t *testing.T) {
+	ot := newOAuthTest(t)
+	owner := ot.accounts.addUser("Owner", "owner@example.com")
+	other := ot.accounts.addUser("Other", "other@example.com")
+
+	// The provider account may use any email, verified or not, when linked.
+	linked := oauthtest.User{Subject: "owner-github", Email: "someone@example.com", EmailVerified: false}
+
+	if _, err := ot.callback("", ot.start(t, owner.Id.String(), linked)); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("link finished on the login route = %v, want ErrInvalidOAuthState", err)
+	}
+	if _, err := ot.callback(other.Id.String(), ot.start(t, owner.Id.String(), linked)); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("link finished by another user = %v, want ErrInvalidOAuthState", err)
+	}
+	if _, err := ot.callback(owner.Id.String(), ot.start(t, "", linked)); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("login finished on the link route = %v, want ErrInvalidOAuthState", err)
+	}
+	if len(ot.accounts.identities) != 0 {
+		t.Fatalf("%d identities were linked by rejected callbacks", len(ot.accounts.identities))
+	}
+
+	result, err := ot.callback(owner.Id.String(), ot.start(t, owner.Id.String(), linked))
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if !result.Linked || result.User.Id != owner.Id {
+		t.Fatalf("link = %+v, want the identity linked to %s", result, owner.Id)
+	}
+
+	login, err := ot.callback("", ot.start(t, "", linked))
+	if err != nil {
+		t.Fatalf("login with the linked identity: %v", err)
+	}
+	if login.User.Id != owner.Id || login.Created {
+		t.Errorf("login with the linked identity = %+v, want the account %s", login, owner.Id)
+	}
+
+	if _, err = ot.callback(other.Id.String(), ot.start(t, other.Id.String(), linked)); !errors.Is(err, ErrIdentityLinked) {
+		t.Errorf("linking an identity of another user = %v, want ErrIdentityLinked", err)
+	}
+}
+
+func TestOAuthUnknownProvider(t *testing.T) {
+	ot := newOAuthTest(t)
+
+	if _, _, err := ot.service.Start(context.Background(), oauth.ProviderGitHub, "", ""); !errors.Is(err, ErrUnknownOAuthProvider) {
+		t.Errorf("Start = %v, want ErrUnknownOAuthProvider", err)
+	}
+	if _, err := ot.service.Callback(context.Background(), oauth.ProviderGitHub, "", "code", "state", "nonce"); !errors.Is(err, ErrUnknownOAuthProvider) {
+		t.Errorf("Callback = %v, want ErrUnknownOAuthProvider", err)
+	}
+	if _, err := ot.service.Callback(context.Background(), oauth.ProviderOIDC, "", "code", ulid.Make().String(), "nonce"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("Callback with an unknown state = %v, want ErrInvalidOAuthState", err)
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/neokofg/callap-backend/internal/application/config"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	passkeyRegistrationPurpose = "passkey_registration"
+	passkeyLoginPurpose        = "passkey_login"
+	passkeyChallengeTTL        = 5 * time.Minute
+	passkeyMaxPerUser          = 20
+)
+
+var (
+	ErrPasskeyChallenge = errors.New("passkey challenge not found or expired")
+	ErrInvalidPasskey   = errors.New("passkey verification failed")
+	ErrPasskeyExists    = errors.New("passkey is already registered")
+	ErrTooManyPasskeys  = errors.New("passkey limit reached")
+)
+
+// The repositories used by PasskeyService, narrowed so the ceremonies can be
+// tested with a software authenticator without a database.
+type (
+	passkeyRepository interface {
+		Create(c context.Context, passkey entity.Passkey) (entity.Passkey, error)
+		List(c context.Context, userId string) ([]entity.Passkey, error)
+		Use(c context.Context, id string, credential []byte) error
+		Delete(c context.Context, userId string, id string) error
+	}
+	passkeyUserRepository interface {
+		GetById(c context.Context, id string) (entity.User, error)
+	}
+	passkeyTokenRepository interface {
+		StoreOneTime(c context.Context, purpose string, hash string, value string, ttl time.Duration) error
+		ConsumeOneTime(c context.Context, purpose string, hash string) (string, error)
+	}
+)
+
+// PasskeyService runs the WebAuthn registration and login ceremonies. The
+// state of a ceremony lives in Redis between its begin and finish calls and
+// can only be used once.
+type PasskeyService struct {
+	cTimeout  time.Duration
+	webauthn  *webauthn.WebAuthn
+	repo      passkeyRepository
+	userRepo  passkeyUserRepository
+	tokenRepo passkeyTokenRepository
+}
+
+func NewPasskeyService(
+	cTimeout time.Duration,
+	cfg config.WebAuthn,
+	repo passkeyRepository,
+	userRepo passkeyUserRepository,
+	tokenRepo passkeyTokenRepository,
+) (*PasskeyService, error) {
+	timeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    passkeyChallengeTTL,
+		TimeoutUVD: passkeyChallengeTTL,
+	}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.Origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyService{
+		cTimeout:  cTimeout,
+		webauthn:  wa,
+		repo:      repo,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+	}, nil
+}
+
+// BeginRegistration returns the options for navigator.credentials.create.
+// Passkeys are created as discoverable credentials with user verification so
+// they can later be used without typing an email.
+func (ps *PasskeyService) BeginRegistration(c context.Context, user entity.User) (*protocol.CredentialCreation, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	passkeys, err := ps.repo.List(c, user.Id.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(passkeys) >= passkeyMaxPerUser {
+		return nil, ErrTooManyPasskeys
+	}
+
+	pu, err := newPasskeyUser(user, passkeys)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := ps.webauthn.BeginRegistration(
+		pu,
+		webauthn.WithExclusions(webauthn.Credentials(pu.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the latest registration of a user is pending at a time.
+	if err = ps.storeSession(c, passkeyRegistrationPurpose, user.Id.String(), session); err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+// FinishRegistration verifies the authenticator response to the options of
+// BeginRegistration and stores the new passkey under name.
+func (ps *PasskeyService) FinishRegistration(c context.Context, user entity.User, name string, response []byte) (entity.Passkey, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	session, err := ps.consumeSession(c, passkeyRegistrationPurpose, user.Id.String())
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return entity.Passkey{}, fmt.Errorf("%w: %w", ErrInvalidPasskey, err)
+	}
+
+	passkeys, err := ps.repo.List(c, user.Id.String())
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+	pu, err := newPasskeyUser(user, passkeys)
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+
+	credential, err := ps.webauthn.CreateCredential(pu, session, parsed)
+	if err != nil {
+		return entity.Passkey{}, fmt.Errorf("%w: %w", ErrInvalidPasskey, err)
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+
+	passkey, err := ps.repo.Create(c, entity.Passkey{
+		UserId:       user.Id,
+		Name:         name,
+		CredentialId: credential.ID,
+		Credential:   record,
+	})
+	if repository.IsUniqueViolation(err, repository.PasskeysCredentialIdConstraint) {
+		return entity.Passkey{}, ErrPasskeyExists
+	}
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+	return passkey, nil
+}
+
+// BeginLogin returns the options for navigator.credentials.get. No user is
+// known yet: the authenticator picks a discoverable credential and reports
+// its owner through the user handle.
+func (ps *PasskeyService) BeginLogin(c context.Context) (*protocol.CredentialAssertion, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	assertion, session, err := ps.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The challenge comes back inside the signed client data, so it doubles
+	// as the key of the pending login.
+	if err = ps.storeSession(c, passkeyLoginPurpose, hashToken(session.Challenge), session); err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+// FinishLogin verifies an assertion for a challenge issued by BeginLogin and
+// returns the owner of the passkey.
+func (ps *PasskeyService) FinishLogin(c context.Context, response []byte) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("%w: %w", ErrInvalidPasskey, err)
+	}
+
+	session, err := ps.consumeSession(c, passkeyLoginPurpose, hashToken(parsed.Response.CollectedClientData.Challenge))
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	var pu passkeyUser
+	var passkeys []entity.Passkey
+	handler := func(_ []byte, userHandle []byte) (webauthn.User, error) {
+		if len(userHandle) != len(ulid.ULID{}) {
+			return nil, fmt.Errorf("malformed user handle")
+		}
+		user, err := ps.userRepo.GetById(c, ulid.ULID(userHandle).String())
+		if err != nil {
+			return nil, err
+		}
+		passkeys, err = ps.repo.List(c, user.Id.String())
+		if err != nil {
+			return nil, err
+		}
+		pu, err = newPasskeyUser(user, passkeys)
+		return pu, err
+	}
+
+	_, credential, err := ps.webauthn.ValidatePasskeyLogin(handler, session, parsed)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("%w: %w", ErrInvalidPasskey, err)
+	}
+	// A counter that went backwards means the private key exists twice.
+	if credential.Authenticator.CloneWarning {
+		return entity.User{}, fmt.Errorf("%w: sign counter did not increase", ErrInvalidPasskey)
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		return entity.User{}, err
+	}
+	for _, passkey := range passkeys {
+		if bytes.Equal(passkey.CredentialId, credential.ID) {
+			if err = ps.repo.Use(c, passkey.Id.String(), record); err != nil {
+				return entity.User{}, err
+			}
+			break
+		}
+	}
+
+	return pu.user, nil
+}
+
+func (ps *PasskeyService) List(c context.Context, userId string) ([]entity.Passkey, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	return ps.repo.List(c, userId)
+}
+
+func (ps *PasskeyService) Delete(c context.Context, userId string, id string) error {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	return ps.repo.Delete(c, userId, id)
+}
+
+func (ps *PasskeyService) storeSession(c context.Context, purpose string, key string, session *webauthn.SessionData) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return ps.tokenRepo.StoreOneTime(c, purpose, key, string(value), passkeyChallengeTTL)
+}
+
+func (ps *PasskeyService) consumeSession(c context.Context, purpose string, key string) (webauthn.SessionData, error) {
+	value, err := ps.tokenRepo.ConsumeOneTime(c, purpose, key)
+	if err != nil {
+		return webauthn.SessionData{}, ErrPasskeyChallenge
+	}
+
+	var session webauthn.SessionData
+	if err = json.Unmarshal([]byte(value), &session); err != nil {
+		return webauthn.SessionData{}, ErrPasskeyChallenge
+	}
+	return session, nil
+}
+
+// passkeyUser adapts a user and their stored passkeys to webauthn.User. The
+// user handle is the raw ULID, so it carries no personal data.
+type passkeyUser struct {
+	user        entity.User
+	credentials []webauthn.Credential
+}
+
+func newPasskeyUser(user entity.User, passkeys []entity.Passkey) (passkeyUser, error) {
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(passkey.Credential, &credential); err != nil {
+			return passkeyUser{}, fmt.Errorf("failed to decode passkey %s: %w", passkey.Id.String(), err)
+		}
+		credentials = append(credentials, credential)
+	}
+	return passkeyUser{user: user, credentials: credentials}, nil
+}
+
+func (pu passkeyUser) WebAuthnID() []byte {
+	return pu.user.Id[:]
+}
+
+func (pu passkeyUser) WebAuthnName() string {
+	return fmt.Sprintf("%s#%s", pu.user.Name, pu.user.Tag)
+}
+
+func (pu passkeyUser) WebAuthnDisplayName() string {
+	return pu.user.Name
+}
+
+func (pu passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return pu.credentials
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/neokofg/callap-backend/internal/application/config"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	passkeyTestRPID   = "localhost"
+	passkeyTestOrigin = "http://localhost:3000"
+)
+
+// memoryPasskeys stores passkeys in memory in place of Postgres.
+type memoryPasskeys struct {
+	passkeys []entity.Passkey
+}
+
+func (m *memoryPasskeys) Create(c context.Context, passkey entity.Passkey) (entity.Passkey, error) {
+	passkey = entity.NewPasskey(passkey)
+	m.passkeys = append(m.passkeys, passkey)
+	return passkey, nil
+}
+
+func (m *memoryPasskeys) List(c context.Context, userId string) ([]entity.Passkey, error) {
+	var passkeys []entity.Passkey
+	for _, passkey := range m.passkeys {
+		if passkey.UserId.String() == userId {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (m *memoryPasskeys) Use(c context.Context, id string, credential []byte) error {
+	for i := range m.passkeys {
+		if m.passkeys[i].Id.String() == id {
+			now := time.Now()
+			m.passkeys[i].Credential = credential
+			m.passkeys[i].LastUsedAt = &now
+			return nil
+		}
+	}
+	return errors.New("passkey not found")
+}
+
+func (m *memoryPasskeys) Delete(c context.Context, userId string, id string) error {
+	return errors.New("not implemented")
+}
+
+// softAuthenticator is a platform authenticator in software: it holds one
+// ES256 key, answers ceremonies with "none" attestation and always reports
+// user presence and verification. Fields can be changed between ceremonies
+// to produce responses a real authenticator would not.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	userHandle   []byte
+	rpId         string
+	origin       string
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, user entity.User) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	credentialId := make([]byte, 16)
+	_, _ = rand.Read(credentialId)
+
+	return &softAuthenticator{
+		key:          key,
+		credentialId: credentialId,
+		userHandle:   user.Id[:],
+		rpId:         passkeyTestRPID,
+		origin:       passkeyTestOrigin,
+	}
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge.String(),
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatalf("marshal client data: %v", err)
+	}
+	return data
+}
+
+// authData encodes the authenticator data with user presence and
+// verification, and the attested credential when attested is set.
+func (a *softAuthenticator) authData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	data := append(rpIdHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialId)))
+	data = append(data, a.credentialId...)
+	return append(data, publicKey...)
+}
+
+// register answers the options of BeginRegistration.
+func (a *softAuthenticator) register(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(t, true),
+	})
+	if err != nil {
+		t.Fatalf("marshal attestation: %v", err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    encode(a.clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encode(attestation),
+	})
+}
+
+// login answers the options of BeginLogin with a signed assertion.
+func (a *softAuthenticator) login(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	clientData := a.clientData(t, "webauthn.get", assertion.Response.Challenge)
+	authData := a.authData(t, false)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) response(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"id":                      encode(a.credentialId),
+		"rawId":                   encode(a.credentialId),
+		"type":                    "public-key",
+		"authenticatorAttachment": "platform",
+		"clientExtensionResults":  map[string]any{},
+		"response":                response,
+	})
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return data
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+type passkeyTest struct {
+	accounts *memoryAccounts
+	passkeys *memoryPasskeys
+	service  *PasskeyService
+}
+
+func newPasskeyTest(t *testing.T) *passkeyTest {
+	t.Helper()
+	accounts := newMemoryAccounts()
+	passkeys := &memoryPasskeys{}
+	service, err := NewPasskeyService(
+		5*time.Second,
+		config.WebAuthn{RPID: passkeyTestRPID, RPName: "Callap", Origins: []string{passkeyTestOrigin}},
+		passkeys, accounts, newMemoryTokens(),
+	)
+	if err != nil {
+		t.Fatalf("NewPasskeyService: %v", err)
+	}
+	return &passkeyTest{accounts: accounts, passkeys: passkeys, service: service}
+}
+
+func (pt *passkeyTest) register(t *testing.T, user entity.User, authenticator *softAuthenticator) {
+	t.Helper()
+	creation, err := pt.service.BeginRegistration(context.Background(), user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if _, err = pt.service.FinishRegistration(context.Background(), user, "laptop", authenticator.register(t, creation)); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+}
+
+func (pt *passkeyTest) login(t *testing.T, authenticator *softAuthenticator) (entity.User, error) {
+	t.Helper()
+	assertion, err := pt.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	return pt.service.FinishLogin(context.Background(), authenticator.login(t, assertion))
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	pt := newPasskeyTest(t)
+	user := pt.accounts.addUser("Alice", "alice@example.com")
+	authenticator := newSoftAuthenticator(t, user)
+
+	pt.register(t, user, authenticator)
+	if len(pt.passkeys.passkeys) != 1 || !bytes.Equal(pt.passkeys.passkeys[0].CredentialId, authenticator.credentialId) {
+		t.Fatalf("stored passkeys = %+v, want the credential of the authenticator", pt.passkeys.passkeys)
+	}
+
+	for count := uint32(1); count <= 2; count++ {
+		authenticator.signCount = count
+		got, err := pt.login(t, authenticator)
+		if err != nil {
+			t.Fatalf("login %d: %v", count, err)
+		}
+		if got.Id != user.Id {
+			t.Errorf("login %d returned %s, want %s", count, got.Id, user.Id)
+		}
+	}
+	if pt.passkeys.passkeys[0].LastUsedAt == nil {
+		t.Errorf("LastUsedAt was not set by the login")
+	}
+}
+
+func TestPasskeyRegistrationRejectsForeignOriginAndRPID(t *testing.T) {
+	pt := newPasskeyTest(t)
+	user := pt.accounts.addUser("Alice", "alice@example.com")
+
+	for name, change := range map[string]func(*softAuthenticator){
+		"origin": func(a *softAuthenticator) { a.origin = "https://evil.example" },
+		"rpid":   func(a *softAuthenticator) { a.rpId = "evil.example" },
+	} {
+		authenticator := newSoftAuthenticator(t, user)
+		change(authenticator)
+
+		creation, err := pt.service.BeginRegistration(context.Background(), user)
+		if err != nil {
+			t.Fatalf("BeginRegistration: %v", err)
+		}
+		_, err = pt.service.FinishRegistration(context.Background(), user, "laptop", authenticator.register(t, creation))
+		if !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("registration with a foreign %s = %v, want ErrInvalidPasskey", name, err)
+		}
+	}
+	if len(pt.passkeys.passkeys) != 0 {
+		t.Errorf("%d passkeys were stored", len(pt.passkeys.passkeys))
+	}
+}
+
+func TestPasskeyLoginRejectsForeignOriginAndRPID(t *testing.T) {
+	pt := newPasskeyTest(t)
+	user := pt.accounts.addUser("Alice", "alice@example.com")
+	authenticator := newSoftAuthenticator(t, user)
+	pt.register(t, user, authenticator)
+
+	authenticator.origin = "https://evil.example"
+	if _, err := pt.login(t, authenticator); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("login from a foreign origin = %v, want ErrInvalidPasskey", err)
+	}
+
+	authenticator.origin = passkeyTestOrigin
+	authenticator.rpId = "evil.example"
+	if _, err := pt.login(t, authenticator); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("login for a foreign RP ID = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyChallengeIsSingleUse(t *testing.T) {
+	pt := newPasskeyTest(t)
+	user := pt.accounts.addUser("Alice", "alice@example.com")
+	authenticator := newSoftAuthenticator(t, user)
+
+	creation, err := pt.service.BeginRegistration(context.Background(), user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	registration := authenticator.register(t, creation)
+	if _, err = pt.service.FinishRegistration(context.Background(), user, "laptop", registration); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if _, err = pt.service.FinishRegistration(context.Background(), user, "laptop", registration); !errors.Is(err, ErrPasskeyChallenge) {
+		t.Errorf("replayed registration = %v, want ErrPasskeyChallenge", err)
+	}
+
+	assertion, err := pt.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	authenticator.signCount = 1
+	login := authenticator.login(t, assertion)
+	if _, err = pt.service.FinishLogin(context.Background(), login); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if _, err = pt.service.FinishLogin(context.Background(), login); !errors.Is(err, ErrPasskeyChallenge) {
+		t.Errorf("replayed login = %v, want ErrPasskeyChallenge", err)
+	}
+}
+
+func TestPasskeyLoginRejectsCloneWarning(t *testing.T) {
+	pt := newPasskeyTest(t)
+	user := pt.accounts.addUser("Alice", "alice@example.com")
+	authenticator := newSoftAuthenticator(t, user)
+	pt.register(t, user, authenticator)
+
+	authenticator.signCount = 5
+	if _, err := pt.login(t, authenticator); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// A copy of the key that has signed less often than the original.
+	authenticator.signCount = 3
+	if _, err := pt.login(t, authenticator); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("login with a sign counter that went backwards = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyLoginRejectsBadUserHandle(t *testing.T) {
+	pt := newPasskeyTest(t)
+	user := pt.accounts.addUser("Alice", "alice@example.com")
+	authenticator := newSoftAuthenticator(t, user)
+	pt.register(t, user, authenticator)
+
+	other := ulid.Make()
+	for name, handle := range map[string][]byte{
+		"malformed":    []byte("abc"),
+		"empty":        nil,
+		"unknown user": other[:],
+	} {
+		authenticator.userHandle = handle
+		if _, err := pt.login(t, authenticator); !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("login with a %s user handle = %v, want ErrInvalidPasskey", name, err)
+		}
+	}
+}
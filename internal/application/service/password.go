@@ -1,33 +1,214 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+var errPasswordMismatch = errors.New("password mismatch")
+
+// PasswordHasher produces self-describing encoded hashes: the prefix of the
+// encoded string tells which hasher created it and with which parameters.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encoded string) error
+	Matches(encoded string) bool
+	NeedsRehash(encoded string) bool
+}
+
+type PasswordConfig struct {
+	Algorithm     string
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+	BcryptCost    int
+}
+
 type PasswordService struct {
-	cost int
+	hasher  PasswordHasher
+	hashers []PasswordHasher
 }
 
-func NewPasswordService() *PasswordService {
-	cost := bcrypt.DefaultCost
+func NewPasswordService(config PasswordConfig) (*PasswordService, error) {
+	argon2Hasher := &Argon2idHasher{
+		memory:     config.Argon2Memory,
+		time:       config.Argon2Time,
+		threads:    config.Argon2Threads,
+		saltLength: 16,
+		keyLength:  32,
+	}
+	bcryptHasher := &BcryptHasher{cost: config.BcryptCost}
+
+	var hasher PasswordHasher
+	switch config.Algorithm {
+	case PasswordAlgorithmArgon2id:
+		hasher = argon2Hasher
+	case PasswordAlgorithmBcrypt:
+		hasher = bcryptHasher
+	default:
+		return nil, fmt.Errorf("unsupported password algorithm: %s", config.Algorithm)
+	}
 
-	return &PasswordService{cost: cost}
+	return &PasswordService{
+		hasher:  hasher,
+		hashers: []PasswordHasher{argon2Hasher, bcryptHasher},
+	}, nil
 }
 
 func (ps *PasswordService) HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), ps.cost)
+	hashedPassword, err := ps.hasher.Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
-	return string(hashedPassword), nil
+	return hashedPassword, nil
 }
 
 func (ps *PasswordService) CheckPassword(password, hashedPassword string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	for _, hasher := range ps.hashers {
+		if hasher.Matches(hashedPassword) {
+			if err := hasher.Verify(password, hashedPassword); err != nil {
+				return fmt.Errorf("password mismatch: %w", err)
+			}
+			return nil
+		}
+	}
+	return errors.New("password mismatch: unknown hash format")
+}
+
+// NeedsRehash reports whether the hash was made by another algorithm than the
+// configured one, or by the same algorithm with outdated parameters.
+func (ps *PasswordService) NeedsRehash(hashedPassword string) bool {
+	if !ps.hasher.Matches(hashedPassword) {
+		return true
+	}
+	return ps.hasher.NeedsRehash(hashedPassword)
+}
+
+type Argon2idHasher struct {
+	memory     uint32
+	time       uint32
+	threads    uint8
+	saltLength uint32
+	keyLength  uint32
+}
+
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func (ah *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, ah.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, ah.time, ah.memory, ah.threads, ah.keyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, ah.memory, ah.time, ah.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (ah *Argon2idHasher) Verify(password string, encoded string) error {
+	h, err := ah.decode(encoded)
 	if err != nil {
-		return fmt.Errorf("password mismatch: %w", err)
+		return err
+	}
+
+	key := argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	if subtle.ConstantTimeCompare(key, h.key) != 1 {
+		return errPasswordMismatch
 	}
 	return nil
 }
+
+func (ah *Argon2idHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (ah *Argon2idHasher) NeedsRehash(encoded string) bool {
+	h, err := ah.decode(encoded)
+	if err != nil {
+		return true
+	}
+	return h.memory != ah.memory || h.time != ah.time || h.threads != ah.threads || uint32(len(h.key)) != ah.keyLength
+}
+
+func (ah *Argon2idHasher) decode(encoded string) (argon2idHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2idHash{}, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return argon2idHash{}, errors.New("unsupported argon2id version")
+	}
+
+	var h argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2id params: %w", err)
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	return h, nil
+}
+
+// BcryptHasher is kept for hashes created before argon2id became the default.
+// bcrypt only looks at the first 72 bytes, so longer passwords are refused
+// instead of being silently truncated.
+type BcryptHasher struct {
+	cost int
+}
+
+func (bh *BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bh.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func (bh *BcryptHasher) Verify(password string, encoded string) error {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+}
+
+func (bh *BcryptHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (bh *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+	return cost != bh.cost
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+)
+
+const (
+	passwordResetPurpose  = "password_reset"
+	passwordResetTTL      = time.Hour
+	passwordResetCooldown = time.Minute
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+type PasswordResetService struct {
+	cTimeout    time.Duration
+	tokenRepo   *repository.TokenRepository
+	mailService *MailService
+}
+
+func NewPasswordResetService(cTimeout time.Duration, tokenRepo *repository.TokenRepository, mailService *MailService) *PasswordResetService {
+	return &PasswordResetService{
+		cTimeout:    cTimeout,
+		tokenRepo:   tokenRepo,
+		mailService: mailService,
+	}
+}
+
+// SendReset mails a reset link unless one was sent moments ago. Callers must
+// not reveal the outcome to the client, otherwise the endpoint would tell
+// which emails are registered.
+func (prs *PasswordResetService) SendReset(c context.Context, user entity.User) error {
+	c, cancel := context.WithTimeout(c, prs.cTimeout)
+	defer cancel()
+
+	ok, err := prs.tokenRepo.AcquireCooldown(c, passwordResetPurpose+":"+user.Id.String(), passwordResetCooldown)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = prs.tokenRepo.StoreOneTime(c, passwordResetPurpose, hashToken(token), user.Id.String(), passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	prs.mailService.SendPasswordReset(user.Email, token)
+
+	return nil
+}
+
+func (prs *PasswordResetService) ConsumeReset(c context.Context, token string) (string, error) {
+	c, cancel := context.WithTimeout(c, prs.cTimeout)
+	defer cancel()
+
+	userId, err := prs.tokenRepo.ConsumeOneTime(c, passwordResetPurpose, hashToken(token))
+	if err != nil {
+		return "", ErrInvalidResetToken
+	}
+	return userId, nil
+}
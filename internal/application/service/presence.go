@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	// PresenceHeartbeatInterval is how often a connected socket refreshes
+	// the presence of its user; presenceTTL leaves room for one missed beat.
+	PresenceHeartbeatInterval = 30 * time.Second
+	presenceTTL               = 2*PresenceHeartbeatInterval + 15*time.Second
+	customStatusMaxLength     = 128
+)
+
+var (
+	ErrInvalidPresenceStatus = errors.New("status must be one of online, idle, dnd or invisible")
+	ErrCustomStatusTooLong   = errors.New("custom status is too long")
+	ErrCustomStatusExpired   = errors.New("custom status must expire in the future")
+)
+
+// PresenceService tracks who is connected and tells their friends. Users
+// pick a status (online, idle, dnd or invisible) that applies while they are
+// connected; invisible users look offline to everyone else and their
+// last-seen time stands still. Only accepted friends receive updates, so a
+// block, which ends the friendship, also hides presence.
+type PresenceService struct {
+	cTimeout         time.Duration
+	repo             *repository.PresenceRepository
+	friendRepo       *repository.FriendRepository
+	websocketService *WebsocketService
+	logger           *zap.Logger
+}
+
+func NewPresenceService(
+	cTimeout time.Duration,
+	repo *repository.PresenceRepository,
+	friendRepo *repository.FriendRepository,
+	websocketService *WebsocketService,
+	logger *zap.Logger,
+) *PresenceService {
+	return &PresenceService{
+		cTimeout:         cTimeout,
+		repo:             repo,
+		friendRepo:       friendRepo,
+		websocketService: websocketService,
+		logger:           logger,
+	}
+}
+
+// Heartbeat keeps the user online. It is called when their socket connects
+// and then every PresenceHeartbeatInterval; friends hear about it when the
+// user was offline before.
+func (ps *PresenceService) Heartbeat(c context.Context, userId string) error {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	cameOnline, err := ps.repo.Heartbeat(c, userId, presenceTTL)
+	if err != nil {
+		return err
+	}
+
+	presence, err := ps.get(c, userId)
+	if err != nil {
+		return err
+	}
+	if presence.Status == entity.PresenceInvisible {
+		return nil
+	}
+
+	if err = ps.repo.SetLastSeen(c, userId, time.Now().UTC()); err != nil {
+		return err
+	}
+	if cameOnline {
+		ps.broadcast(c, presence)
+	}
+	return nil
+}
+
+// Disconnect marks the user offline once their last socket is gone.
+func (ps *PresenceService) Disconnect(c context.Context, userId string) error {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	presence, err := ps.get(c, userId)
+	if err != nil {
+		return err
+	}
+	if err = ps.repo.SetOffline(c, userId); err != nil {
+		return err
+	}
+	// Friends already see invisible users as offline.
+	if presence.Status == entity.PresenceInvisible || presence.Status == entity.PresenceOffline {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if err = ps.repo.SetLastSeen(c, userId, now); err != nil {
+		return err
+	}
+	presence.Status = entity.PresenceOffline
+	presence.LastSeenAt = &now
+	ps.broadcast(c, presence)
+	return nil
+}
+
+// SetStatus changes the status the user shows while connected.
+func (ps *PresenceService) SetStatus(c context.Context, userId string, status entity.PresenceStatus) (entity.Presence, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	switch status {
+	case entity.PresenceOnline, entity.PresenceIdle, entity.PresenceDND, entity.PresenceInvisible:
+	default:
+		return entity.Presence{}, ErrInvalidPresenceStatus
+	}
+
+	if err := ps.repo.SetStatus(c, userId, status); err != nil {
+		return entity.Presence{}, err
+	}
+	return ps.update(c, userId)
+}
+
+// SetCustomStatus shows text next to the status of the user until expiresAt,
+// or until it is changed when expiresAt is nil. An empty text clears it.
+func (ps *PresenceService) SetCustomStatus(c context.Context, userId string, text string, expiresAt *time.Time) (entity.Presence, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	if len([]rune(text)) > customStatusMaxLength {
+		return entity.Presence{}, ErrCustomStatusTooLong
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return entity.Presence{}, ErrCustomStatusExpired
+	}
+
+	var status *entity.CustomStatus
+	if text != "" {
+		status = &entity.CustomStatus{Text: text, ExpiresAt: expiresAt}
+	}
+	if err := ps.repo.SetCustomStatus(c, userId, status); err != nil {
+		return entity.Presence{}, err
+	}
+	return ps.update(c, userId)
+}
+
+// Get returns the presence of the user as they see it themselves.
+func (ps *PresenceService) Get(c context.Context, userId string) (entity.Presence, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	return ps.get(c, userId)
+}
+
+// List returns the presence of the given users as others see it.
+func (ps *PresenceService) List(c context.Context, userIds []string) (map[string]entity.Presence, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	presences, err := ps.repo.Get(c, userIds)
+	if err != nil {
+		return nil, err
+	}
+	for userId, presence := range presences {
+		presences[userId] = publicPresence(presence)
+	}
+	return presences, nil
+}
+
+func (ps *PresenceService) get(c context.Context, userId string) (entity.Presence, error) {
+	presences, err := ps.repo.Get(c, []string{userId})
+	if err != nil {
+		return entity.Presence{}, err
+	}
+	return presences[userId], nil
+}
+
+func (ps *PresenceService) update(c context.Context, userId string) (entity.Presence, error) {
+	presence, err := ps.get(c, userId)
+	if err != nil {
+		return entity.Presence{}, err
+	}
+	ps.broadcast(c, presence)
+	return presence, nil
+}
+
+// broadcast sends the public view of the presence to the friends of the
+// user as a "presence.updated" event.
+func (ps *PresenceService) broadcast(c context.Context, presence entity.Presence) {
+	friendIds, err := ps.friendRepo.ListIds(c, presence.UserId)
+	if err != nil {
+		ps.logger.Error("Failed to list friends", zap.Error(err), zap.String("userId", presence.UserId))
+		return
+	}
+
+	msg := Message{
+		Type:   "presence.updated",
+		UserID: presence.UserId,
+		Data:   publicPresence(presence),
+	}
+	for _, friendId := range friendIds {
+		ps.websocketService.SendToUser(friendId, msg)
+	}
+}
+
+// publicPresence is presence as others see it: invisible users look like
+// any offline user, without a custom status that would give them away.
+func publicPresence(presence entity.Presence) entity.Presence {
+	if presence.Status == entity.PresenceInvisible {
+		presence.Status = entity.PresenceOffline
+		presence.CustomStatus = nil
+		presence.CustomStatusExpiresAt = nil
+	}
+	return presence
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// ProfileNameCooldown is how long a user has to wait between two changes of
+// their name#tag, which is what others use to find and add them.
+const ProfileNameCooldown = 7 * 24 * time.Hour
+
+var (
+	ErrProfileNameCooldown = errors.New("name and tag can only be changed once a week")
+	ErrNameTagTaken        = errors.New("this name and tag combination is already taken")
+	ErrEmailTaken          = errors.New("an account with this email already exists")
+)
+
+// ProfileUpdate lists the fields to change; nil fields are left as they are.
+type ProfileUpdate struct {
+	Name         *string
+	Tag          *string
+	Email        *string
+	Discoverable *bool
+}
+
+type ProfileService struct {
+	cTimeout            time.Duration
+	userRepo            *repository.UserRepository
+	friendRepo          *repository.FriendRepository
+	verificationService *VerificationService
+	websocketService    *WebsocketService
+	store               storage.Store
+	logger              *zap.Logger
+}
+
+func NewProfileService(
+	cTimeout time.Duration,
+	userRepo *repository.UserRepository,
+	friendRepo *repository.FriendRepository,
+	verificationService *VerificationService,
+	websocketService *WebsocketService,
+	store storage.Store,
+	logger *zap.Logger,
+) *ProfileService {
+	return &ProfileService{
+		cTimeout:            cTimeout,
+		userRepo:            userRepo,
+		friendRepo:          friendRepo,
+		verificationService: verificationService,
+		websocketService:    websocketService,
+		store:               store,
+		logger:              logger,
+	}
+}
+
+// Update applies update to the user. A new email is only kept as pending:
+// password resets and login links keep going to the current, verified address
+// until the user opens the link sent to the new one. Asking for the current
+// email again drops a pending change. A new name or tag is pushed to friends
+// as a "user.updated" event.
+func (ps *ProfileService) Update(c context.Context, user entity.User, update ProfileUpdate) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	updated := user
+	if update.Name != nil {
+		updated.Name = *update.Name
+	}
+	if update.Tag != nil {
+		updated.Tag = *update.Tag
+	}
+	if update.Email != nil {
+		updated.PendingEmail = update.Email
+		if *update.Email == user.Email {
+			updated.PendingEmail = nil
+		}
+	}
+	if update.Discoverable != nil {
+		updated.Discoverable = *update.Discoverable
+	}
+
+	nameChanged := updated.Name != user.Name || updated.Tag != user.Tag
+	emailRequested := updated.PendingEmail != nil && (user.PendingEmail == nil || *updated.PendingEmail != *user.PendingEmail)
+	emailCanceled := updated.PendingEmail == nil && user.PendingEmail != nil
+	if !nameChanged && !emailRequested && !emailCanceled && updated.Discoverable == user.Discoverable {
+		return user, nil
+	}
+
+	if nameChanged && user.NameChangedAt != nil && time.Since(*user.NameChangedAt) < ProfileNameCooldown {
+		return entity.User{}, ErrProfileNameCooldown
+	}
+
+	// The address is checked again when the change is confirmed, in case it
+	// was taken in the meantime.
+	if emailRequested {
+		if _, err := ps.userRepo.GetByEmail(c, *updated.PendingEmail); err == nil {
+			return entity.User{}, ErrEmailTaken
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return entity.User{}, err
+		}
+	}
+
+	updated, err := ps.userRepo.UpdateProfile(c, updated)
+	if repository.IsUniqueViolation(err, repository.UsersNameTagConstraint) {
+		return entity.User{}, ErrNameTagTaken
+	}
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if emailRequested {
+		if err = ps.verificationService.SendEmailVerification(c, updated); err != nil {
+			ps.logger.Error("Failed to send verification email", zap.Error(err), zap.String("userId", updated.Id.String()))
+		}
+	}
+	if nameChanged {
+		ps.notifyFriends(c, updated)
+	}
+
+	return updated, nil
+}
+
+// SetAvatar replaces the avatar of the user with the given image and tells
+// their friends about it.
+func (ps *ProfileService) SetAvatar(c context.Context, user entity.User, data []byte) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	userId := user.Id.String()
+	avatarId := ulid.Make().String()
+	if err := storeImage(c, ps.store, avatarImage, userId, avatarId, data); err != nil {
+		ps.removeImage(c, avatarImage, userId, &avatarId)
+		return entity.User{}, err
+	}
+
+	previous, err := ps.userRepo.SetAvatar(c, userId, &avatarId)
+	if err != nil {
+		ps.removeImage(c, avatarImage, userId, &avatarId)
+		return entity.User{}, err
+	}
+	ps.removeImage(c, avatarImage, userId, previous)
+
+	user.AvatarId = &avatarId
+	ps.notifyFriends(c, user)
+	return user, nil
+}
+
+func (ps *ProfileService) RemoveAvatar(c context.Context, user entity.User) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	previous, err := ps.userRepo.SetAvatar(c, user.Id.String(), nil)
+	if err != nil {
+		return entity.User{}, err
+	}
+	ps.removeImage(c, avatarImage, user.Id.String(), previous)
+
+	user.AvatarId = nil
+	ps.notifyFriends(c, user)
+	return user, nil
+}
+
+// SetBanner replaces the profile banner of the user with the given image.
+func (ps *ProfileService) SetBanner(c context.Context, user entity.User, data []byte) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	userId := user.Id.String()
+	bannerId := ulid.Make().String()
+	if err := storeImage(c, ps.store, bannerImage, userId, bannerId, data); err != nil {
+		ps.removeImage(c, bannerImage, userId, &bannerId)
+		return entity.User{}, err
+	}
+
+	previous, err := ps.userRepo.SetBanner(c, userId, &bannerId)
+	if err != nil {
+		ps.removeImage(c, bannerImage, userId, &bannerId)
+		return entity.User{}, err
+	}
+	ps.removeImage(c, bannerImage, userId, previous)
+
+	user.BannerId = &bannerId
+	return user, nil
+}
+
+func (ps *ProfileService) RemoveBanner(c context.Context, user entity.User) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, ps.cTimeout)
+	defer cancel()
+
+	previous, err := ps.userRepo.SetBanner(c, user.Id.String(), nil)
+	if err != nil {
+		return entity.User{}, err
+	}
+	ps.removeImage(c, bannerImage, user.Id.String(), previous)
+
+	user.BannerId = nil
+	return user, nil
+}
+
+// OpenImage returns a resized variant of a profile image, as linked by
+// AvatarURL and BannerURL. Originals are kept but never served. The reader
+// outlives the call, so c is not bounded by cTimeout.
+func (ps *ProfileService) OpenImage(c context.Context, dir string, userId string, imageId string, variant string) (io.ReadCloser, error) {
+	kind, ok := imageKinds[dir]
+	if !ok || !kind.hasVariant(variant) {
+		return nil, ErrImageNotFound
+	}
+	if _, err := ulid.ParseStrict(userId); err != nil {
+		return nil, ErrImageNotFound
+	}
+	if _, err := ulid.ParseStrict(imageId); err != nil {
+		return nil, ErrImageNotFound
+	}
+
+	img, err := ps.store.Open(c, kind.key(userId, imageId, variant))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrImageNotFound
+	}
+	return img, err
+}
+
+func (ps *ProfileService) removeImage(c context.Context, kind imageKind, userId string, imageId *string) {
+	if err := deleteImage(c, ps.store, kind, userId, imageId); err != nil {
+		ps.logger.Error("Failed to delete profile image", zap.Error(err), zap.String("userId", userId))
+	}
+}
+
+// userUpdatedEvent is the payload of "user.updated".
+type userUpdatedEvent struct {
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	Tag       string  `json:"tag"`
+	AvatarURL *string `json:"avatar_url"`
+}
+
+func (ps *ProfileService) notifyFriends(c context.Context, user entity.User) {
+	friendIds, err := ps.friendRepo.ListIds(c, user.Id.String())
+	if err != nil {
+		ps.logger.Error("Failed to list friends", zap.Error(err), zap.String("userId", user.Id.String()))
+		return
+	}
+
+	msg := Message{
+		Type:   "user.updated",
+		UserID: user.Id.String(),
+		Data: userUpdatedEvent{
+			Id:        user.Id.String(),
+			Name:      user.Name,
+			Tag:       user.Tag,
+			AvatarURL: AvatarURL(user.Id.String(), user.AvatarId),
+		},
+	}
+	for _, friendId := range friendIds {
+		ps.websocketService.SendToUser(friendId, msg)
+	}
+}
@@ -5,30 +5,106 @@ import (
 
 	"github.com/neokofg/callap-backend/internal/application/config"
 	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/internal/infrastructure/mail"
+	"github.com/neokofg/callap-backend/internal/infrastructure/storage"
+	"github.com/neokofg/callap-backend/pkg/encryption"
 	"github.com/neokofg/callap-backend/pkg/jwt"
 	"go.uber.org/zap"
 )
 
 type Services struct {
-	JWT                 *jwt.Service
-	UserService         *UserService
-	PasswordService     *PasswordService
-	FriendService       *FriendService
-	ConversationService *ConversationService
-	WebsocketService    *WebsocketService
+	JWT                    *jwt.Service
+	UserService            *UserService
+	PasswordService        *PasswordService
+	FriendService          *FriendService
+	ConversationService    *ConversationService
+	WebsocketService       *WebsocketService
+	SessionService         *SessionService
+	MailService            *MailService
+	VerificationService    *VerificationService
+	PasswordResetService   *PasswordResetService
+	TwoFactorService       *TwoFactorService
+	LoginThrottleService   *LoginThrottleService
+	OAuthService           *OAuthService
+	APITokenService        *APITokenService
+	BotService             *BotService
+	WebhookService         *WebhookService
+	MagicLinkService       *MagicLinkService
+	PasskeyService         *PasskeyService
+	AccountDeletionService *AccountDeletionService
+	ExportService          *ExportService
+	ProfileService         *ProfileService
+	PresenceService        *PresenceService
 }
 
-func NewServices(cfg *config.Config, repositories *repository.Repositories, logger *zap.Logger) *Services {
+func NewServices(cfg *config.Config, repositories *repository.Repositories, mailer mail.Mailer, store storage.Store, logger *zap.Logger) *Services {
 	c := time.Duration(cfg.ContextTimeout) * time.Second
 
 	wsService := NewWebsocketService(c, logger)
+	mailService := NewMailService(c, mailer, cfg.FrontendURL, logger)
+
+	jwtService, err := jwt.NewService(jwt.Config(cfg.JWT), repositories.TokenRepository)
+	if err != nil {
+		logger.Fatal("failed to init jwt service", zap.Error(err))
+	}
+
+	passwordService, err := NewPasswordService(PasswordConfig{
+		Algorithm:     cfg.Password.Algorithm,
+		Argon2Memory:  cfg.Password.Argon2Memory,
+		Argon2Time:    cfg.Password.Argon2Time,
+		Argon2Threads: cfg.Password.Argon2Threads,
+		BcryptCost:    cfg.Password.BcryptCost,
+	})
+	if err != nil {
+		logger.Fatal("failed to init password service", zap.Error(err))
+	}
+
+	cipher, err := encryption.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		logger.Fatal("failed to init encryption cipher", zap.Error(err))
+	}
+
+	friendService, err := NewFriendService(c, time.Duration(cfg.FriendRequestTTL)*24*time.Hour, repositories.FriendRepository, repositories.UserRepository, wsService, logger)
+	if err != nil {
+		logger.Fatal("failed to init friend service", zap.Error(err))
+	}
+
+	passkeyService, err := NewPasskeyService(c, cfg.WebAuthn, repositories.PasskeyRepository, repositories.UserRepository, repositories.TokenRepository)
+	if err != nil {
+		logger.Fatal("failed to init passkey service", zap.Error(err))
+	}
+
+	apiTokenService := NewAPITokenService(c, repositories.APITokenRepository)
+	webhookService := NewWebhookService(repositories.BotRepository, cipher, logger)
+
+	verificationService := NewVerificationService(c, repositories.UserRepository, repositories.TokenRepository, mailService)
+	sessionService := NewSessionService(c, repositories.SessionRepository, repositories.UserRepository, jwtService)
+
+	loginThrottleService := NewLoginThrottleService(c, repositories.LoginAttemptRepository)
+	loginThrottleService.OnLockout(NewLockoutNotifier(repositories.UserRepository, mailService, wsService).Notify)
 
 	return &Services{
-		JWT:                 jwt.NewService(jwt.Config(cfg.JWT)),
-		UserService:         NewUserService(c, repositories.UserRepository),
-		PasswordService:     NewPasswordService(),
-		FriendService:       NewFriendService(c, repositories.FriendRepository),
-		ConversationService: NewConversationService(c, repositories.ConversationRepository, wsService),
-		WebsocketService:    wsService,
+		JWT:                    jwtService,
+		UserService:            NewUserService(c, repositories.UserRepository),
+		PasswordService:        passwordService,
+		FriendService:          friendService,
+		ConversationService:    NewConversationService(c, repositories.ConversationRepository, repositories.UserRepository, repositories.FriendRepository, wsService, webhookService),
+		WebsocketService:       wsService,
+		SessionService:         sessionService,
+		MailService:            mailService,
+		VerificationService:    verificationService,
+		PasswordResetService:   NewPasswordResetService(c, repositories.TokenRepository, mailService),
+		TwoFactorService:       NewTwoFactorService(c, repositories.UserRepository, repositories.TokenRepository, cipher, cfg.TOTPIssuer),
+		LoginThrottleService:   loginThrottleService,
+		OAuthService:           NewOAuthService(c, NewOAuthProviders(cfg.OAuth), repositories.UserRepository, repositories.IdentityRepository, repositories.TokenRepository),
+		APITokenService:        apiTokenService,
+		BotService:             NewBotService(c, repositories.BotRepository, apiTokenService, cipher),
+		WebhookService:         webhookService,
+		MagicLinkService:       NewMagicLinkService(c, repositories.UserRepository, repositories.TokenRepository, mailService),
+		PasskeyService:         passkeyService,
+		AccountDeletionService: NewAccountDeletionService(c, repositories.UserRepository, repositories.APITokenRepository, sessionService, mailService, store, logger),
+		ExportService:          NewExportService(c, repositories.UserRepository, repositories.FriendRepository, repositories.ConversationRepository, repositories.TokenRepository, store, wsService, logger),
+		ProfileService:         NewProfileService(c, repositories.UserRepository, repositories.FriendRepository, verificationService, wsService, store, logger),
+		PresenceService:        NewPresenceService(c, repositories.PresenceRepository, repositories.FriendRepository, wsService, logger),
 	}
 }
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/pkg/jwt"
+	"github.com/oklog/ulid/v2"
+)
+
+// ErrRefreshTokenReused is returned by Renew when another refresh with the
+// same token rotated the session first.
+var ErrRefreshTokenReused = repository.ErrSessionRotated
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type SessionService struct {
+	cTimeout   time.Duration
+	repo       *repository.SessionRepository
+	userRepo   *repository.UserRepository
+	jwtService *jwt.Service
+}
+
+func NewSessionService(cTimeout time.Duration, repo *repository.SessionRepository, userRepo *repository.UserRepository, jwtService *jwt.Service) *SessionService {
+	return &SessionService{
+		cTimeout:   cTimeout,
+		repo:       repo,
+		userRepo:   userRepo,
+		jwtService: jwtService,
+	}
+}
+
+func (ss *SessionService) HashToken(token string) string {
+	return hashToken(token)
+}
+
+// Start opens a new session for the user and issues its first token pair.
+// Logging in during the grace period of an account deletion cancels it.
+func (ss *SessionService) Start(c context.Context, user entity.User, session entity.Session) (TokenPair, error) {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	if user.DeletedAt != nil {
+		if err := ss.userRepo.CancelDeletion(c, user.Id.String()); err != nil {
+			return TokenPair{}, err
+		}
+	}
+
+	session.Id = ulid.Make()
+	session.UserId = user.Id
+
+	tokens, err := ss.generateTokens(user, session.Id)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	session.RefreshTokenHash = hashToken(tokens.RefreshToken)
+	if _, err = ss.repo.Create(c, session); err != nil {
+		return TokenPair{}, err
+	}
+	return tokens, nil
+}
+
+// Renew issues a new token pair for an existing session and rotates the
+// stored refresh token hash, invalidating the previous refresh token. When
+// the stored hash changed since session was read, the refresh token was used
+// twice and ErrRefreshTokenReused is returned.
+func (ss *SessionService) Renew(c context.Context, user entity.User, session entity.Session) (TokenPair, error) {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	tokens, err := ss.generateTokens(user, session.Id)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	previousHash := session.RefreshTokenHash
+	session.RefreshTokenHash = hashToken(tokens.RefreshToken)
+	if err = ss.repo.Rotate(c, session, previousHash); err != nil {
+		return TokenPair{}, err
+	}
+	return tokens, nil
+}
+
+func (ss *SessionService) generateTokens(user entity.User, sessionId ulid.ULID) (TokenPair, error) {
+	body := map[string]interface{}{
+		"name":       user.Name,
+		"tag":        user.Tag,
+		"created_at": user.CreatedAt,
+		"updated_at": user.UpdatedAt,
+		"sid":        sessionId.String(),
+	}
+
+	token, err := ss.jwtService.GenerateToken(user.Id, body)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	refreshBody := map[string]interface{}{
+		"type": "refresh",
+		"sid":  sessionId.String(),
+	}
+
+	refreshToken, err := ss.jwtService.GenerateToken(user.Id, refreshBody)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return TokenPair{AccessToken: token, RefreshToken: refreshToken}, nil
+}
+
+func (ss *SessionService) GetById(c context.Context, id string) (entity.Session, error) {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	return ss.repo.GetById(c, id)
+}
+
+func (ss *SessionService) List(c context.Context, userId string, currentId string) ([]entity.ActiveSession, error) {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	sessions, err := ss.repo.List(c, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]entity.ActiveSession, 0, len(sessions))
+	for _, session := range sessions {
+		active = append(active, entity.ActiveSession{
+			ID:         session.Id.String(),
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			Ip:         session.Ip,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.Id.String() == currentId,
+		})
+	}
+	return active, nil
+}
+
+func (ss *SessionService) Revoke(c context.Context, userId string, id string) error {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	if err := ss.repo.Delete(c, userId, id); err != nil {
+		return err
+	}
+
+	return ss.jwtService.InvalidateSession(c, id)
+}
+
+func (ss *SessionService) RevokeOthers(c context.Context, userId string, keepId string) error {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	ids, err := ss.repo.DeleteOthers(c, userId, keepId)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err = ss.jwtService.InvalidateSession(c, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ss *SessionService) RevokeAll(c context.Context, userId string) error {
+	c, cancel := context.WithTimeout(c, ss.cTimeout)
+	defer cancel()
+
+	if err := ss.jwtService.InvalidateUserTokens(c, ulid.MustParse(userId)); err != nil {
+		return err
+	}
+
+	return ss.repo.DeleteAll(c, userId)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+)
+
+const (
+	// tagAlphabet is Crockford's base32, the same alphabet as our ULIDs: no
+	// I, L, O or U, so tags are easy to read out loud and type.
+	tagAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	tagLength   = 3
+	tagAttempts = 10
+)
+
+var ErrNoFreeTag = errors.New("no free tag left for this name")
+
+func randomTag() (string, error) {
+	tag := make([]byte, tagLength)
+	for i := range tag {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(tagAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate tag: %w", err)
+		}
+		tag[i] = tagAlphabet[n.Int64()]
+	}
+	return string(tag), nil
+}
+
+// tagFinder looks up the first tag that is still free for a name.
+type tagFinder interface {
+	FirstFreeTag(c context.Context, name string, alphabet string) (string, error)
+}
+
+// withRandomTag calls create with random tags until one does not collide with
+// an existing name#tag pair. Popular names can make a few random picks
+// collide, so it then falls back to the first free tag found by tags and only
+// gives up with ErrNoFreeTag once there is none.
+func withRandomTag(c context.Context, tags tagFinder, name string, create func(tag string) error) error {
+	for i := 0; i < tagAttempts; i++ {
+		tag, err := randomTag()
+		if err != nil {
+			return err
+		}
+
+		err = create(tag)
+		if !repository.IsUniqueViolation(err, repository.UsersNameTagConstraint) {
+			return err
+		}
+	}
+
+	var err error
+	for i := 0; i < tagAttempts; i++ {
+		var tag string
+		tag, err = tags.FirstFreeTag(c, name, tagAlphabet)
+		if err != nil {
+			return err
+		}
+		if tag == "" {
+			return ErrNoFreeTag
+		}
+
+		// Someone else may take the tag in between, so look again.
+		err = create(tag)
+		if !repository.IsUniqueViolation(err, repository.UsersNameTagConstraint) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/pkg/encryption"
+	"github.com/neokofg/callap-backend/pkg/totp"
+)
+
+const (
+	twoFactorChallengePurpose = "two_factor_challenge"
+	twoFactorChallengeTTL     = 5 * time.Minute
+	twoFactorMaxAttempts      = 5
+	recoveryCodeCount         = 10
+)
+
+var (
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled  = errors.New("two-factor enrollment was not started")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	ErrInvalidTwoFactorToken = errors.New("invalid or expired challenge token")
+)
+
+type TwoFactorService struct {
+	cTimeout  time.Duration
+	userRepo  *repository.UserRepository
+	tokenRepo *repository.TokenRepository
+	cipher    *encryption.Cipher
+	issuer    string
+}
+
+func NewTwoFactorService(
+	cTimeout time.Duration,
+	userRepo *repository.UserRepository,
+	tokenRepo *repository.TokenRepository,
+	cipher *encryption.Cipher,
+	issuer string,
+) *TwoFactorService {
+	return &TwoFactorService{
+		cTimeout:  cTimeout,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		cipher:    cipher,
+		issuer:    issuer,
+	}
+}
+
+// Enroll stores a fresh, not yet active secret and returns it along with the
+// otpauth URI for authenticator apps. It only takes effect after Confirm.
+func (tfs *TwoFactorService) Enroll(c context.Context, user entity.User) (string, string, error) {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	if user.TOTPEnabledAt != nil {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := tfs.cipher.Encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err = tfs.userRepo.SetTOTPSecret(c, user.Id.String(), encrypted); err != nil {
+		return "", "", err
+	}
+
+	return secret, totp.URI(tfs.issuer, user.Email, secret), nil
+}
+
+func (tfs *TwoFactorService) Confirm(c context.Context, user entity.User, code string) ([]string, error) {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err := tfs.checkTOTP(c, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err = tfs.userRepo.EnableTOTP(c, user.Id.String(), hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (tfs *TwoFactorService) Disable(c context.Context, user entity.User, code string) error {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	if err := tfs.verify(c, user, code); err != nil {
+		return err
+	}
+	return tfs.userRepo.DisableTOTP(c, user.Id.String())
+}
+
+func (tfs *TwoFactorService) RegenerateRecoveryCodes(c context.Context, user entity.User, code string) ([]string, error) {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := tfs.checkTOTP(c, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err = tfs.userRepo.SetRecoveryCodes(c, user.Id.String(), hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CreateChallenge issues the short-lived token returned by login in place of
+// access/refresh tokens when the account has two-factor authentication on.
+func (tfs *TwoFactorService) CreateChallenge(c context.Context, user entity.User, deviceName string) (string, error) {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = tfs.tokenRepo.StoreOneTime(c, twoFactorChallengePurpose, hashToken(token), user.Id.String()+"|"+deviceName, twoFactorChallengeTTL)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ChallengeUser returns the user a pending challenge was issued to, so that
+// failed codes can be throttled like failed passwords.
+func (tfs *TwoFactorService) ChallengeUser(c context.Context, token string) (entity.User, error) {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	value, err := tfs.tokenRepo.PeekOneTime(c, twoFactorChallengePurpose, hashToken(token))
+	if err != nil {
+		return entity.User{}, ErrInvalidTwoFactorToken
+	}
+	userId, _, _ := strings.Cut(value, "|")
+
+	user, err := tfs.userRepo.GetById(c, userId)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// ResolveChallenge checks the code for the challenge and, on success, consumes
+// it and returns the user id and device name captured at login.
+func (tfs *TwoFactorService) ResolveChallenge(c context.Context, token string, code string) (string, string, error) {
+	c, cancel := context.WithTimeout(c, tfs.cTimeout)
+	defer cancel()
+
+	hash := hashToken(token)
+	value, err := tfs.tokenRepo.PeekOneTime(c, twoFactorChallengePurpose, hash)
+	if err != nil {
+		return "", "", ErrInvalidTwoFactorToken
+	}
+	userId, deviceName, _ := strings.Cut(value, "|")
+
+	user, err := tfs.userRepo.GetById(c, userId)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err = tfs.verify(c, user, code); err != nil {
+		attempts, countErr := tfs.tokenRepo.CountAttempt(c, twoFactorChallengePurpose+":"+hash, twoFactorChallengeTTL)
+		if countErr == nil && attempts >= twoFactorMaxAttempts {
+			_, _ = tfs.tokenRepo.ConsumeOneTime(c, twoFactorChallengePurpose, hash)
+		}
+		return "", "", err
+	}
+
+	if _, err = tfs.tokenRepo.ConsumeOneTime(c, twoFactorChallengePurpose, hash); err != nil {
+		return "", "", ErrInvalidTwoFactorToken
+	}
+	return userId, deviceName, nil
+}
+
+// verify accepts either a current TOTP code or one of the unused recovery codes.
+func (tfs *TwoFactorService) verify(c context.Context, user entity.User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := tfs.checkTOTP(c, user, code); err == nil {
+		return nil
+	}
+
+	used, err := tfs.userRepo.UseRecoveryCode(c, user.Id.String(), hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (tfs *TwoFactorService) checkTOTP(c context.Context, user entity.User, code string) error {
+	secret, err := tfs.cipher.Decrypt(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// A code stays valid for the whole skew window, so remember the step to
+	// stop an observed code from being replayed.
+	fresh, err := tfs.tokenRepo.AcquireCooldown(c, fmt.Sprintf("totp:%s:%d", user.Id.String(), step), (2*totp.Skew+1)*totp.Period)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -2,12 +2,24 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/neokofg/callap-backend/internal/domain/entity"
 	"github.com/neokofg/callap-backend/internal/domain/repository"
 )
 
+const userSearchMinLength = 2
+
+var (
+	ErrSearchQueryTooShort = errors.New("search for at least two characters of a name")
+	ErrInvalidSearchCursor = errors.New("invalid search cursor")
+)
+
 type UserService struct {
 	cTimeout time.Duration
 	repo     *repository.UserRepository
@@ -41,11 +53,37 @@ func (us *UserService) GetByEmail(c context.Context, email string) (entity.User,
 	return us.repo.GetByEmail(c, email)
 }
 
+// Create stores a new user. Without a tag, a free random one is picked for the
+// name; a requested tag that is already paired with the name is reported as
+// ErrNameTagTaken.
 func (us *UserService) Create(c context.Context, user entity.User) (entity.User, error) {
 	c, cancel := context.WithTimeout(c, us.cTimeout)
 	defer cancel()
 
-	return us.repo.Create(c, user)
+	var created entity.User
+	create := func(tag string) error {
+		user.Tag = tag
+		var err error
+		created, err = us.repo.Create(c, user)
+		return err
+	}
+
+	var err error
+	if user.Tag == "" {
+		err = withRandomTag(c, us.repo, user.Name, create)
+	} else {
+		err = create(user.Tag)
+	}
+	if repository.IsUniqueViolation(err, repository.UsersNameTagConstraint) {
+		return entity.User{}, ErrNameTagTaken
+	}
+	if repository.IsUniqueViolation(err, repository.UsersEmailConstraint) {
+		return entity.User{}, ErrEmailTaken
+	}
+	if err != nil {
+		return entity.User{}, err
+	}
+	return created, nil
 }
 
 func (us *UserService) Update(c context.Context, user entity.User) (entity.User, error) {
@@ -54,3 +92,62 @@ func (us *UserService) Update(c context.Context, user entity.User) (entity.User,
 
 	return us.repo.Update(c, user)
 }
+
+// Search looks users up by name for userId, friends and friends of friends
+// first. The query is a name, optionally followed by "#" and the start of a
+// tag. It returns up to limit results and the cursor of the next page, which
+// is empty on the last one.
+func (us *UserService) Search(c context.Context, userId string, query string, cursor string, limit int) ([]entity.UserSearchResult, string, error) {
+	c, cancel := context.WithTimeout(c, us.cTimeout)
+	defer cancel()
+
+	name, tagPrefix, _ := strings.Cut(query, "#")
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) < userSearchMinLength {
+		return nil, "", ErrSearchQueryTooShort
+	}
+
+	var after *entity.UserSearchCursor
+	if cursor != "" {
+		decoded, err := decodeSearchCursor(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidSearchCursor
+		}
+		after = &decoded
+	}
+
+	// One extra row tells whether there is a next page.
+	results, err := us.repo.Search(c, userId, name, strings.TrimSpace(tagPrefix), after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(results) <= limit {
+		return results, "", nil
+	}
+
+	results = results[:limit]
+	last := results[limit-1]
+	next, err := encodeSearchCursor(entity.UserSearchCursor{Rank: last.Rank, Id: last.Id.String()})
+	if err != nil {
+		return nil, "", err
+	}
+	return results, next, nil
+}
+
+func encodeSearchCursor(cursor entity.UserSearchCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSearchCursor(cursor string) (entity.UserSearchCursor, error) {
+	var decoded entity.UserSearchCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return decoded, err
+	}
+	err = json.Unmarshal(data, &decoded)
+	return decoded, err
+}
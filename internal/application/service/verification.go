@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+)
+
+const (
+	emailVerificationPurpose  = "email_verification"
+	emailVerificationTTL      = 24 * time.Hour
+	emailVerificationCooldown = time.Minute
+)
+
+var (
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrVerificationCooldown     = errors.New("verification email was sent recently")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+)
+
+type VerificationService struct {
+	cTimeout    time.Duration
+	userRepo    *repository.UserRepository
+	tokenRepo   *repository.TokenRepository
+	mailService *MailService
+}
+
+func NewVerificationService(
+	cTimeout time.Duration,
+	userRepo *repository.UserRepository,
+	tokenRepo *repository.TokenRepository,
+	mailService *MailService,
+) *VerificationService {
+	return &VerificationService{
+		cTimeout:    cTimeout,
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		mailService: mailService,
+	}
+}
+
+// SendEmailVerification mails a verification link to the email the user asked
+// to switch to, or to their current email while it is unverified.
+func (vs *VerificationService) SendEmailVerification(c context.Context, user entity.User) error {
+	c, cancel := context.WithTimeout(c, vs.cTimeout)
+	defer cancel()
+
+	email := user.Email
+	if user.PendingEmail != nil {
+		email = *user.PendingEmail
+	} else if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	ok, err := vs.tokenRepo.AcquireCooldown(c, emailVerificationPurpose+":"+user.Id.String(), emailVerificationCooldown)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrVerificationCooldown
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	// The email is stored next to the user id so that a link sent to one
+	// address cannot verify another one the user switched to afterwards.
+	err = vs.tokenRepo.StoreOneTime(c, emailVerificationPurpose, hashToken(token), user.Id.String()+"|"+email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	vs.mailService.SendEmailVerification(email, token)
+
+	return nil
+}
+
+// VerifyEmail consumes a verification link. A link sent to a pending email
+// switches the account over to it.
+func (vs *VerificationService) VerifyEmail(c context.Context, token string) (string, error) {
+	c, cancel := context.WithTimeout(c, vs.cTimeout)
+	defer cancel()
+
+	value, err := vs.tokenRepo.ConsumeOneTime(c, emailVerificationPurpose, hashToken(token))
+	if err != nil {
+		return "", ErrInvalidVerificationToken
+	}
+
+	userId, email, found := strings.Cut(value, "|")
+	if !found {
+		return "", ErrInvalidVerificationToken
+	}
+
+	user, err := vs.userRepo.GetById(c, userId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	switch {
+	case user.Email == email:
+		if err = vs.userRepo.MarkEmailVerified(c, userId); err != nil {
+			return "", err
+		}
+	case user.PendingEmail != nil && *user.PendingEmail == email:
+		changed, err := vs.userRepo.ConfirmEmailChange(c, userId, email)
+		if repository.IsUniqueViolation(err, repository.UsersEmailConstraint) {
+			return "", ErrEmailTaken
+		}
+		if err != nil {
+			return "", err
+		}
+		if !changed {
+			return "", ErrInvalidVerificationToken
+		}
+	default:
+		return "", ErrInvalidVerificationToken
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/domain/repository"
+	"github.com/neokofg/callap-backend/pkg/encryption"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 3
+)
+
+// WebhookService delivers events to bots that registered an outgoing webhook.
+// Each request carries X-Callap-Timestamp and X-Callap-Signature, the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the bot's webhook secret.
+type WebhookService struct {
+	botRepo *repository.BotRepository
+	cipher  *encryption.Cipher
+	client  *http.Client
+	logger  *zap.Logger
+}
+
+func NewWebhookService(botRepo *repository.BotRepository, cipher *encryption.Cipher, logger *zap.Logger) *WebhookService {
+	return &WebhookService{
+		botRepo: botRepo,
+		cipher:  cipher,
+		client:  newWebhookClient(),
+		logger:  logger,
+	}
+}
+
+// Deliver sends msg to the webhooks of the bots among userIds. It returns
+// immediately; delivery happens in the background with a few retries.
+func (whs *WebhookService) Deliver(userIds []string, msg Message) {
+	if len(userIds) == 0 {
+		return
+	}
+
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		targets, err := whs.botRepo.WebhookTargets(c, userIds)
+		cancel()
+		if err != nil {
+			whs.logger.Error("Failed to load bot webhooks", zap.Error(err))
+			return
+		}
+
+		body, err := json.Marshal(msg)
+		if err != nil {
+			whs.logger.Error("Failed to encode webhook event", zap.Error(err))
+			return
+		}
+
+		for _, target := range targets {
+			go whs.send(target, msg.Type, body)
+		}
+	}()
+}
+
+func (whs *WebhookService) send(target entity.BotWebhook, event string, body []byte) {
+	secret, err := whs.cipher.Decrypt(target.Secret)
+	if err != nil {
+		whs.logger.Error("Failed to decrypt webhook secret", zap.Error(err), zap.String("botId", target.BotId.String()))
+		return
+	}
+
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err = whs.post(target.URL, secret, event, body)
+		if err == nil {
+			return
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	whs.logger.Warn("Failed to deliver webhook", zap.Error(err), zap.String("botId", target.BotId.String()))
+}
+
+func (whs *WebhookService) post(url string, secret string, event string, body []byte) error {
+	c, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(c, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Callap-Event", event)
+	req.Header.Set("X-Callap-Timestamp", timestamp)
+	req.Header.Set("X-Callap-Signature", "sha256="+signWebhook(secret, timestamp, body))
+
+	resp, err := whs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newWebhookClient returns a client that only talks to public addresses.
+// The address is checked when dialing, after DNS resolution, so a hostname
+// that changed since checkWebhookURL cannot point it inward. Redirects are
+// not followed and count as failed deliveries.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkWebhookURL accepts absolute https URLs whose host only resolves to
+// public addresses.
+func checkWebhookURL(c context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(c, u.Hostname())
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && !dnsErr.IsTimeout && !dnsErr.IsTemporary {
+			return ErrInvalidWebhookURL
+		}
+		return err
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return ErrInvalidWebhookURL
+		}
+	}
+	return nil
+}
+
+// isPublicIP rejects loopback, private, link-local (which covers cloud
+// metadata endpoints), multicast and unspecified addresses.
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
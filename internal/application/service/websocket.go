@@ -54,10 +54,18 @@ func (ws *WebsocketService) Join(userId string, conn *websocket.Conn) {
 	ws.hub.mu.Unlock()
 }
 
-func (ws *WebsocketService) Leave(userId string) {
+// Leave forgets conn unless it was already replaced by a newer connection of
+// the user, and reports whether the user is left without a connection.
+func (ws *WebsocketService) Leave(userId string, conn *websocket.Conn) bool {
 	ws.hub.mu.Lock()
-	delete(ws.hub.conns, userId)
-	ws.hub.mu.Unlock()
+	defer ws.hub.mu.Unlock()
+
+	current, ok := ws.hub.conns[userId]
+	if ok && current == conn {
+		delete(ws.hub.conns, userId)
+		return true
+	}
+	return !ok
 }
 
 func (ws *WebsocketService) SendToUser(userId string, msg Message) {
@@ -78,3 +86,16 @@ func (ws *WebsocketService) SendToUser(userId string, msg Message) {
 		ws.logger.Debug("Message sent to user", zap.String("userId", userId))
 	}
 }
+
+func (ws *WebsocketService) Disconnect(userId string) {
+	ws.hub.mu.Lock()
+	conn, ok := ws.hub.conns[userId]
+	delete(ws.hub.conns, userId)
+	ws.hub.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := conn.Close(); err != nil {
+		ws.logger.Warn("Error closing websocket", zap.Error(err), zap.String("userId", userId))
+	}
+}
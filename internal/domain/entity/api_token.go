@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// APIToken is a long-lived, scoped credential for scripts and bots. Only the
+// hash of the token is stored; Prefix keeps its first characters so users can
+// tell their tokens apart.
+type APIToken struct {
+	Id         ulid.ULID  `json:"id"`
+	UserId     ulid.ULID  `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func NewAPIToken(t APIToken) APIToken {
+	id := ulid.Make()
+	if t.Id != ulid.Zero {
+		id = t.Id
+	}
+
+	return APIToken{
+		Id:         id,
+		UserId:     t.UserId,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		TokenHash:  t.TokenHash,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
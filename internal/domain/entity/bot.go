@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Bot is the owner's view of a bot account.
+type Bot struct {
+	Id         ulid.ULID `json:"id"`
+	Name       string    `json:"name"`
+	Tag        string    `json:"tag"`
+	WebhookURL *string   `json:"webhook_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BotWebhook is where events for a bot are delivered, with the encrypted
+// secret used to sign them.
+type BotWebhook struct {
+	BotId  ulid.ULID
+	URL    string
+	Secret string
+}
@@ -21,13 +21,14 @@ type Conversation struct {
 }
 
 type ConversationSummary struct {
-	ID            string     `json:"id"`
-	OtherUserID   string     `json:"other_user_id"`
-	OtherUserName string     `json:"other_user_name"`
-	OtherUserTag  string     `json:"other_user_tag"`
-	LastMessage   *string    `json:"last_message"`
-	LastMessageAt *time.Time `json:"last_message_at"`
-	UnreadCount   int        `json:"unread_count"`
+	ID                string     `json:"id"`
+	OtherUserID       string     `json:"other_user_id"`
+	OtherUserName     string     `json:"other_user_name"`
+	OtherUserTag      string     `json:"other_user_tag"`
+	OtherUserAvatarId *string    `json:"-"`
+	LastMessage       *string    `json:"last_message"`
+	LastMessageAt     *time.Time `json:"last_message_at"`
+	UnreadCount       int        `json:"unread_count"`
 }
 
 type ConversationDetails struct {
@@ -40,8 +40,20 @@ func NewFriend(f Friend) Friend {
 }
 
 type PendingFriend struct {
-	ID       string `json:"id"`
-	SenderID string `json:"sender_id"`
-	Name     string `json:"name"`
-	Tag      string `json:"tag"`
+	ID       string  `json:"id"`
+	SenderID string  `json:"sender_id"`
+	Name     string  `json:"name"`
+	Tag      string  `json:"tag"`
+	AvatarId *string `json:"-"`
+}
+
+// OutgoingFriend is a pending request sent by the user; SentAt is when it
+// was last sent.
+type OutgoingFriend struct {
+	ID          string    `json:"id"`
+	RecipientID string    `json:"recipient_id"`
+	Name        string    `json:"name"`
+	Tag         string    `json:"tag"`
+	AvatarId    *string   `json:"-"`
+	SentAt      time.Time `json:"sent_at"`
 }
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Identity links an account at an external provider (provider + subject) to
+// a local user.
+type Identity struct {
+	Id        ulid.ULID `json:"id"`
+	UserId    ulid.ULID `json:"-"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewIdentity(i Identity) Identity {
+	id := ulid.Make()
+	if i.Id != ulid.Zero {
+		id = i.Id
+	}
+
+	return Identity{
+		Id:        id,
+		UserId:    i.UserId,
+		Provider:  i.Provider,
+		Subject:   i.Subject,
+		Email:     i.Email,
+		CreatedAt: i.CreatedAt,
+	}
+}
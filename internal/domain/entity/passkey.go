@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Passkey is a WebAuthn credential registered by a user. Credential holds the
+// JSON encoded credential record (public key, sign counter, flags) as
+// produced by the WebAuthn library; CredentialId is kept apart for lookups.
+type Passkey struct {
+	Id           ulid.ULID  `json:"id"`
+	UserId       ulid.ULID  `json:"-"`
+	Name         string     `json:"name"`
+	CredentialId []byte     `json:"-"`
+	Credential   []byte     `json:"-"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func NewPasskey(p Passkey) Passkey {
+	id := ulid.Make()
+	if p.Id != ulid.Zero {
+		id = p.Id
+	}
+
+	return Passkey{
+		Id:           id,
+		UserId:       p.UserId,
+		Name:         p.Name,
+		CredentialId: p.CredentialId,
+		Credential:   p.Credential,
+		LastUsedAt:   p.LastUsedAt,
+		CreatedAt:    p.CreatedAt,
+	}
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+type PresenceStatus string
+
+const (
+	PresenceOnline    PresenceStatus = "online"
+	PresenceIdle      PresenceStatus = "idle"
+	PresenceDND       PresenceStatus = "dnd"
+	PresenceInvisible PresenceStatus = "invisible"
+	PresenceOffline   PresenceStatus = "offline"
+)
+
+// Presence is what a user shows to others: Status is the status they picked
+// while they are connected and offline otherwise.
+type Presence struct {
+	UserId                string         `json:"user_id"`
+	Status                PresenceStatus `json:"status"`
+	CustomStatus          *string        `json:"custom_status"`
+	CustomStatusExpiresAt *time.Time     `json:"custom_status_expires_at"`
+	LastSeenAt            *time.Time     `json:"last_seen_at"`
+}
+
+// CustomStatus is a short text a user shows next to their status, until
+// ExpiresAt if set.
+type CustomStatus struct {
+	Text      string     `json:"text"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
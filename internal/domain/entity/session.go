@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+type Session struct {
+	Id               ulid.ULID
+	UserId           ulid.ULID
+	DeviceName       string
+	UserAgent        string
+	Ip               string
+	RefreshTokenHash string
+	CreatedAt        time.Time
+	LastSeenAt       time.Time
+}
+
+func NewSession(s Session) Session {
+	id := ulid.Make()
+	if s.Id != ulid.Zero {
+		id = s.Id
+	}
+
+	return Session{
+		Id:               id,
+		UserId:           s.UserId,
+		DeviceName:       s.DeviceName,
+		UserAgent:        s.UserAgent,
+		Ip:               s.Ip,
+		RefreshTokenHash: s.RefreshTokenHash,
+		CreatedAt:        s.CreatedAt,
+		LastSeenAt:       s.LastSeenAt,
+	}
+}
+
+type ActiveSession struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	Ip         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
@@ -7,14 +7,24 @@ import (
 )
 
 type User struct {
-	Id           ulid.ULID
-	Name         string
-	Tag          string
-	Email        string
-	RefreshToken string
-	Password     string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	Id              ulid.ULID
+	Name            string
+	Tag             string
+	Email           string
+	Password        string `json:"-"`
+	EmailVerifiedAt *time.Time
+	PendingEmail    *string
+	TOTPSecret      string `json:"-"`
+	TOTPEnabledAt   *time.Time
+	IsBot           bool
+	BotOwnerId      *ulid.ULID
+	DeletedAt       *time.Time
+	NameChangedAt   *time.Time
+	AvatarId        *string
+	BannerId        *string
+	Discoverable    bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 func NewUser(u User) User {
@@ -24,19 +34,30 @@ func NewUser(u User) User {
 	}
 
 	return User{
-		Id:           id,
-		Name:         u.Name,
-		Tag:          u.Tag,
-		Email:        u.Email,
-		Password:     u.Password,
-		CreatedAt:    u.CreatedAt,
-		UpdatedAt:    u.UpdatedAt,
-		RefreshToken: u.RefreshToken,
+		Id:              id,
+		Name:            u.Name,
+		Tag:             u.Tag,
+		Email:           u.Email,
+		Password:        u.Password,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		PendingEmail:    u.PendingEmail,
+		TOTPSecret:      u.TOTPSecret,
+		TOTPEnabledAt:   u.TOTPEnabledAt,
+		IsBot:           u.IsBot,
+		BotOwnerId:      u.BotOwnerId,
+		DeletedAt:       u.DeletedAt,
+		NameChangedAt:   u.NameChangedAt,
+		AvatarId:        u.AvatarId,
+		BannerId:        u.BannerId,
+		Discoverable:    u.Discoverable,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
 	}
 }
 
 type FriendUser struct {
-	Id   ulid.ULID `json:"id"`
-	Name string    `json:"name"`
-	Tag  string    `json:"tag"`
+	Id       ulid.ULID `json:"id"`
+	Name     string    `json:"name"`
+	Tag      string    `json:"tag"`
+	AvatarId *string   `json:"-"`
 }
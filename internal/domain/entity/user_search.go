@@ -0,0 +1,30 @@
+package entity
+
+import "github.com/oklog/ulid/v2"
+
+// UserSearchRank orders search results, each field from most to least
+// relevant: Relation is 2 for friends and 1 for users with mutual friends,
+// Match is 2 for the exact name and 1 for a prefix of it, and Score is the
+// trigram similarity of the name in thousandths.
+type UserSearchRank struct {
+	Relation int
+	Match    int
+	Score    int
+}
+
+type UserSearchResult struct {
+	Id            ulid.ULID
+	Name          string
+	Tag           string
+	AvatarId      *string
+	IsFriend      bool
+	MutualFriends int
+	Rank          UserSearchRank
+}
+
+// UserSearchCursor is the position after which the next page of a search
+// starts.
+type UserSearchCursor struct {
+	Rank UserSearchRank `json:"r"`
+	Id   string         `json:"id"`
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+type APITokenRepository struct {
+	pool      *pgxpool.Pool
+	tableName string
+}
+
+func NewAPITokenRepository(pool *pgxpool.Pool) *APITokenRepository {
+	return &APITokenRepository{
+		pool:      pool,
+		tableName: apiTokensTableName,
+	}
+}
+
+func (atr *APITokenRepository) Create(c context.Context, token entity.APIToken) (entity.APIToken, error) {
+	newToken := entity.NewAPIToken(token)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, user_id, name, prefix, token_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at",
+		atr.tableName,
+	)
+	err := atr.pool.QueryRow(
+		c, query,
+		newToken.Id.String(), newToken.UserId.String(), newToken.Name, newToken.Prefix, newToken.TokenHash, newToken.Scopes, newToken.ExpiresAt,
+	).Scan(&newToken.CreatedAt)
+	if err != nil {
+		return entity.APIToken{}, err
+	}
+	return newToken, nil
+}
+
+func (atr *APITokenRepository) GetByHash(c context.Context, hash string) (entity.APIToken, error) {
+	var token entity.APIToken
+	query := fmt.Sprintf(
+		"SELECT id, user_id, name, prefix, token_hash, scopes, expires_at, last_used_at, created_at FROM %s WHERE token_hash = $1",
+		atr.tableName,
+	)
+	err := atr.pool.QueryRow(c, query, hash).
+		Scan(&token.Id, &token.UserId, &token.Name, &token.Prefix, &token.TokenHash, &token.Scopes, &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return entity.APIToken{}, err
+	}
+	return token, nil
+}
+
+func (atr *APITokenRepository) List(c context.Context, userId string) ([]entity.APIToken, error) {
+	query := fmt.Sprintf(
+		"SELECT id, user_id, name, prefix, token_hash, scopes, expires_at, last_used_at, created_at FROM %s WHERE user_id = $1 ORDER BY created_at DESC",
+		atr.tableName,
+	)
+	rows, err := atr.pool.Query(c, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []entity.APIToken{}
+	for rows.Next() {
+		var token entity.APIToken
+		err = rows.Scan(&token.Id, &token.UserId, &token.Name, &token.Prefix, &token.TokenHash, &token.Scopes, &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (atr *APITokenRepository) Count(c context.Context, userId string) (int, error) {
+	var count int
+	query := fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE user_id = $1",
+		atr.tableName,
+	)
+	err := atr.pool.QueryRow(c, query, userId).Scan(&count)
+	return count, err
+}
+
+// Touch records a use of the token, at most once per minute to keep hot
+// tokens from turning every request into a write.
+func (atr *APITokenRepository) Touch(c context.Context, id string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET last_used_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP AT TIME ZONE 'UTC' - INTERVAL '1 minute')",
+		atr.tableName,
+	)
+	_, err := atr.pool.Exec(c, query, id)
+	return err
+}
+
+func (atr *APITokenRepository) Delete(c context.Context, userId string, id string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND user_id = $2",
+		atr.tableName,
+	)
+	result, err := atr.pool.Exec(c, query, id, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("api token not found or no permission: id=%s, user=%s", id, userId)
+	}
+	return nil
+}
+
+func (atr *APITokenRepository) DeleteAll(c context.Context, userId string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE user_id = $1",
+		atr.tableName,
+	)
+	_, err := atr.pool.Exec(c, query, userId)
+	return err
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// BotRepository manages bot accounts, which live in the users table with
+// is_bot set and an owning human user.
+type BotRepository struct {
+	pool      *pgxpool.Pool
+	tableName string
+}
+
+func NewBotRepository(pool *pgxpool.Pool) *BotRepository {
+	return &BotRepository{
+		pool:      pool,
+		tableName: userTableName,
+	}
+}
+
+// FirstFreeTag is UserRepository.FirstFreeTag; bots share the name#tag
+// space of users.
+func (br *BotRepository) FirstFreeTag(c context.Context, name string, alphabet string) (string, error) {
+	return firstFreeTag(c, br.pool, br.tableName, name, alphabet)
+}
+
+func (br *BotRepository) Create(c context.Context, bot entity.User) (entity.User, error) {
+	newBot := entity.NewUser(bot)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, name, tag, email, password, is_bot, bot_owner_id) VALUES ($1, $2, $3, $4, $5, TRUE, $6) RETURNING created_at, updated_at",
+		br.tableName,
+	)
+	err := br.pool.QueryRow(
+		c, query,
+		newBot.Id.String(), newBot.Name, newBot.Tag, newBot.Email, newBot.Password, newBot.BotOwnerId.String(),
+	).Scan(&newBot.CreatedAt, &newBot.UpdatedAt)
+	if err != nil {
+		return entity.User{}, err
+	}
+	newBot.IsBot = true
+	return newBot, nil
+}
+
+func (br *BotRepository) List(c context.Context, ownerId string) ([]entity.Bot, error) {
+	query := fmt.Sprintf(
+		"SELECT id, name, tag, bot_webhook_url, created_at FROM %s WHERE is_bot AND bot_owner_id = $1 ORDER BY created_at",
+		br.tableName,
+	)
+	rows, err := br.pool.Query(c, query, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bots := []entity.Bot{}
+	for rows.Next() {
+		var bot entity.Bot
+		if err = rows.Scan(&bot.Id, &bot.Name, &bot.Tag, &bot.WebhookURL, &bot.CreatedAt); err != nil {
+			return nil, err
+		}
+		bots = append(bots, bot)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return bots, nil
+}
+
+func (br *BotRepository) Count(c context.Context, ownerId string) (int, error) {
+	var count int
+	query := fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE is_bot AND bot_owner_id = $1",
+		br.tableName,
+	)
+	err := br.pool.QueryRow(c, query, ownerId).Scan(&count)
+	return count, err
+}
+
+func (br *BotRepository) GetOwned(c context.Context, ownerId string, id string) (entity.Bot, error) {
+	var bot entity.Bot
+	query := fmt.Sprintf(
+		"SELECT id, name, tag, bot_webhook_url, created_at FROM %s WHERE id = $1 AND is_bot AND bot_owner_id = $2",
+		br.tableName,
+	)
+	err := br.pool.QueryRow(c, query, id, ownerId).
+		Scan(&bot.Id, &bot.Name, &bot.Tag, &bot.WebhookURL, &bot.CreatedAt)
+	if err != nil {
+		return entity.Bot{}, err
+	}
+	return bot, nil
+}
+
+// SetWebhook replaces the webhook of the bot; nil values remove it.
+func (br *BotRepository) SetWebhook(c context.Context, ownerId string, id string, url *string, secret *string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET bot_webhook_url = $3, bot_webhook_secret = $4, updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND is_bot AND bot_owner_id = $2",
+		br.tableName,
+	)
+	result, err := br.pool.Exec(c, query, id, ownerId, url, secret)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("bot not found or no permission: id=%s, owner=%s", id, ownerId)
+	}
+	return nil
+}
+
+// WebhookTargets returns the webhooks of those users among ids that are bots
+// with a webhook configured.
+func (br *BotRepository) WebhookTargets(c context.Context, ids []string) ([]entity.BotWebhook, error) {
+	query := fmt.Sprintf(
+		"SELECT id, bot_webhook_url, bot_webhook_secret FROM %s WHERE id = ANY($1) AND is_bot AND bot_webhook_url IS NOT NULL",
+		br.tableName,
+	)
+	rows, err := br.pool.Query(c, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []entity.BotWebhook
+	for rows.Next() {
+		var webhook entity.BotWebhook
+		if err = rows.Scan(&webhook.BotId, &webhook.URL, &webhook.Secret); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (br *BotRepository) Delete(c context.Context, ownerId string, id string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND is_bot AND bot_owner_id = $2",
+		br.tableName,
+	)
+	result, err := br.pool.Exec(c, query, id, ownerId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("bot not found or no permission: id=%s, owner=%s", id, ownerId)
+	}
+	return nil
+}
@@ -64,6 +64,18 @@ func (cr *ConversationRepository) GetParticipants(c context.Context, id string)
 	return participants, nil
 }
 
+// IsParticipant reports whether the user belongs to the conversation, hidden
+// or not.
+func (cr *ConversationRepository) IsParticipant(c context.Context, id string, userId string) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(
+		"SELECT EXISTS (SELECT 1 FROM %s WHERE conversation_id = $1 AND user_id = $2)",
+		cr.participantsTableName,
+	)
+	err := cr.pool.QueryRow(c, query, id, userId).Scan(&exists)
+	return exists, err
+}
+
 func (cr *ConversationRepository) ListMessages(c context.Context, userId string, id string, limit int, offset int) ([]entity.Message, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 50
@@ -75,14 +87,14 @@ func (cr *ConversationRepository) ListMessages(c context.Context, userId string,
 	rows, err := cr.pool.Query(c, `
 	SELECT 
             m.id,
-            m.sender_id,
-            u.name AS sender_name,
+            COALESCE(m.sender_id, ''),
+            COALESCE(u.name, 'Deleted user') AS sender_name,
             m.content,
             m.created_at,
             m.is_read,
 			m.conversation_id
         FROM messages m
-        JOIN users u ON m.sender_id = u.id
+        LEFT JOIN users u ON m.sender_id = u.id
         WHERE m.conversation_id = $1
           AND EXISTS (
               SELECT 1 FROM conversation_participants cp 
@@ -111,6 +123,40 @@ func (cr *ConversationRepository) ListMessages(c context.Context, userId string,
 	return messages, nil
 }
 
+// ListSentMessages returns up to limit messages sent by the user in any
+// conversation, hidden and left ones included, oldest first. Pages continue
+// after the message with the given creation time and id; pass the zero time
+// and an empty id for the first page.
+func (cr *ConversationRepository) ListSentMessages(c context.Context, userId string, afterCreatedAt time.Time, afterId string, limit int) ([]entity.Message, error) {
+	query := fmt.Sprintf(`
+        SELECT m.id, m.sender_id, u.name, m.content, m.created_at, m.is_read, m.conversation_id
+        FROM messages m
+        JOIN %s u ON m.sender_id = u.id
+        WHERE m.sender_id = $1 AND (m.created_at, m.id) > ($2, $3)
+        ORDER BY m.created_at, m.id
+        LIMIT $4
+    `, cr.userTableName)
+	rows, err := cr.pool.Query(c, query, userId, afterCreatedAt, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []entity.Message
+	for rows.Next() {
+		var msg entity.Message
+		err = rows.Scan(&msg.ID, &msg.SenderID, &msg.SenderName, &msg.Content, &msg.CreatedAt, &msg.IsRead, &msg.ConversationId)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
 func (cr *ConversationRepository) DeleteMessage(c context.Context, userId string, id string) error {
 	tx, err := cr.pool.Begin(c)
 	if err != nil {
@@ -128,6 +174,8 @@ func (cr *ConversationRepository) DeleteMessage(c context.Context, userId string
 	return tx.Commit(c)
 }
 
+// NewMessage stores a message from userId. Private conversations refuse it
+// with ErrBlocked while either participant blocks the other.
 func (cr *ConversationRepository) NewMessage(c context.Context, userId string, id string, content string) (*entity.Message, error) {
 	tx, err := cr.pool.Begin(c)
 	if err != nil {
@@ -135,6 +183,23 @@ func (cr *ConversationRepository) NewMessage(c context.Context, userId string, i
 	}
 	defer tx.Rollback(c)
 
+	var blocked bool
+	err = tx.QueryRow(c, `
+        SELECT EXISTS (
+            SELECT 1 FROM conversations c
+            JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id != $2
+            JOIN friends f ON f.status = 'blocked'
+                AND ((f.user_id = $2 AND f.friend_id = cp.user_id) OR (f.user_id = cp.user_id AND f.friend_id = $2))
+            WHERE c.id = $1 AND c.type = 'private'
+        )
+    `, id, userId).Scan(&blocked)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
 	messageId := ulid.Make().String()
 
 	_, err = tx.Exec(c, `
@@ -231,6 +296,15 @@ func (cr *ConversationRepository) GetConversationByID(c context.Context, userId
 }
 
 func (cr *ConversationRepository) List(c context.Context, userId string, limit int, offset int) ([]entity.ConversationSummary, error) {
+	return cr.list(c, userId, false, limit, offset)
+}
+
+// ListWithHidden is List including the conversations the user hid or left.
+func (cr *ConversationRepository) ListWithHidden(c context.Context, userId string, limit int, offset int) ([]entity.ConversationSummary, error) {
+	return cr.list(c, userId, true, limit, offset)
+}
+
+func (cr *ConversationRepository) list(c context.Context, userId string, withHidden bool, limit int, offset int) ([]entity.ConversationSummary, error) {
 	if limit <= 0 || limit > 50 {
 		limit = 20
 	}
@@ -241,16 +315,17 @@ func (cr *ConversationRepository) List(c context.Context, userId string, limit i
 	rows, err := cr.pool.Query(c, `
         SELECT 
             c.id AS conversation_id,
-            mp.user_id AS other_user_id,
-            u.name AS other_user_name,
-            u.tag AS other_user_tag,
+            COALESCE(mp.user_id, '') AS other_user_id,
+            COALESCE(u.name, 'Deleted user') AS other_user_name,
+            COALESCE(u.tag, '') AS other_user_tag,
+            u.avatar_id AS other_user_avatar_id,
             m.content AS last_message,
             m.created_at AS last_message_at,
             COALESCE(unread.unread_count, 0) AS unread_count
         FROM conversations c
         JOIN conversation_participants cp ON c.id = cp.conversation_id
-        JOIN conversation_participants mp ON c.id = mp.conversation_id AND mp.user_id != $1
-        JOIN users u ON mp.user_id = u.id
+        LEFT JOIN conversation_participants mp ON c.id = mp.conversation_id AND mp.user_id != $1
+        LEFT JOIN users u ON mp.user_id = u.id
         LEFT JOIN (
             SELECT 
                 conversation_id,
@@ -265,13 +340,13 @@ func (cr *ConversationRepository) List(c context.Context, userId string, limit i
                 COUNT(*) AS unread_count
             FROM messages m
             JOIN conversation_participants cp ON m.conversation_id = cp.conversation_id
-            WHERE m.sender_id != $1 AND m.is_read = FALSE AND cp.user_id = $1
+            WHERE m.sender_id IS DISTINCT FROM $1 AND m.is_read = FALSE AND cp.user_id = $1
             GROUP BY m.conversation_id
         ) unread ON c.id = unread.conversation_id
-        WHERE cp.user_id = $1 AND cp.left_at IS NULL
+        WHERE cp.user_id = $1 AND ($4 OR cp.left_at IS NULL)
         ORDER BY c.updated_at DESC
         LIMIT $2 OFFSET $3
-    `, userId, limit, offset)
+    `, userId, limit, offset, withHidden)
 	if err != nil {
 		return nil, err
 	}
@@ -282,7 +357,7 @@ func (cr *ConversationRepository) List(c context.Context, userId string, limit i
 		var cs entity.ConversationSummary
 		var lastAt *time.Time
 		var lastMessage *string
-		err = rows.Scan(&cs.ID, &cs.OtherUserID, &cs.OtherUserName, &cs.OtherUserTag, &lastMessage, &lastAt, &cs.UnreadCount)
+		err = rows.Scan(&cs.ID, &cs.OtherUserID, &cs.OtherUserName, &cs.OtherUserTag, &cs.OtherUserAvatarId, &lastMessage, &lastAt, &cs.UnreadCount)
 		if err != nil {
 			return nil, err
 		}
@@ -301,11 +376,18 @@ func (cr *ConversationRepository) GetOrCreate(c context.Context, userId string,
 		return "", fmt.Errorf("cannot chat with self")
 	}
 
+	blocked, err := isBlocked(c, cr.pool, friendsTableName, userId, targetId)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return "", ErrBlocked
+	}
+
 	minId, maxId := utils.GetOrderedIds(userId, targetId)
 	cacheKey := fmt.Sprintf("private_chat:%s:%s", minId, maxId)
 
 	var convId string
-	var err error
 
 	convId, err = cr.rdb.Get(c, cacheKey).Result()
 	if err == nil {
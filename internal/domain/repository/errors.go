@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrBlocked is returned when one of two users has blocked the other.
+	ErrBlocked = errors.New("one of the users has blocked the other")
+	// ErrSessionRotated is returned when the refresh token of a session was
+	// rotated by someone else in the meantime.
+	ErrSessionRotated = errors.New("session refresh token was already rotated")
+)
+
+const (
+	UsersNameTagConstraint              = "users_name_tag_key"
+	UsersEmailConstraint                = "users_email_key"
+	IdentitiesProviderSubjectConstraint = "identities_provider_subject_key"
+	PasskeysCredentialIdConstraint      = "webauthn_credentials_credential_id_key"
+)
+
+// IsUniqueViolation reports whether err was caused by the given unique
+// constraint; an empty constraint matches any unique violation.
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return false
+	}
+	return constraint == "" || pgErr.ConstraintName == constraint
+}
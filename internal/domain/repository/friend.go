@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -33,7 +34,7 @@ func (fr *FriendRepository) List(c context.Context, userId string, limit int, of
 	}
 
 	query := fmt.Sprintf(
-		"SELECT u.id, u.name, u.tag FROM %s f JOIN %s u ON f.friend_id = u.id WHERE f.user_id = $1 AND f.status = 'accepted' ORDER BY f.created_at DESC LIMIT $2 OFFSET $3",
+		"SELECT u.id, u.name, u.tag, u.avatar_id FROM %s f JOIN %s u ON f.friend_id = u.id WHERE f.user_id = $1 AND f.status = 'accepted' ORDER BY f.created_at DESC LIMIT $2 OFFSET $3",
 		fr.tableName,
 		fr.userTableName,
 	)
@@ -47,7 +48,7 @@ func (fr *FriendRepository) List(c context.Context, userId string, limit int, of
 	for rows.Next() {
 		var fu entity.FriendUser
 		var idStr string
-		err := rows.Scan(&idStr, &fu.Name, &fu.Tag)
+		err := rows.Scan(&idStr, &fu.Name, &fu.Tag, &fu.AvatarId)
 		if err != nil {
 			return nil, err
 		}
@@ -113,45 +114,44 @@ func (fr *FriendRepository) Delete(c context.Context, userId string, friendId st
 	return tx.Commit(c)
 }
 
-func (fr *FriendRepository) Decline(c context.Context, userId string, id string) error {
-	tx, err := fr.pool.Begin(c)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(c)
-
+// Decline rejects the request with the given id sent to userId and returns
+// the id of its sender.
+func (fr *FriendRepository) Decline(c context.Context, userId string, id string) (string, error) {
+	var senderId string
 	query := fmt.Sprintf(
-		"UPDATE %s SET status = 'rejected', updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND friend_id = $2",
+		"UPDATE %s SET status = 'rejected', updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND friend_id = $2 AND status = 'pending' RETURNING user_id",
 		fr.tableName,
 	)
-	result, err := tx.Exec(c, query, id, userId)
-	if err != nil {
-		return err
+	err := fr.pool.QueryRow(c, query, id, userId).Scan(&senderId)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("friend request not found or no permission: id=%s, user=%s", id, userId)
 	}
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("friend request not found or no permission: id=%s, user=%s", id, userId)
+	if err != nil {
+		return "", err
 	}
-
-	return tx.Commit(c)
+	return senderId, nil
 }
 
-func (fr *FriendRepository) Accept(c context.Context, userId string, id string) error {
+// Accept accepts the request with the given id sent to userId and returns
+// the id of its sender. Only pending requests qualify: Block keeps the id of
+// the row it overwrites, so that id must not turn a block into a friendship.
+func (fr *FriendRepository) Accept(c context.Context, userId string, id string) (string, error) {
 	tx, err := fr.pool.Begin(c)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer tx.Rollback(c)
 
 	query := fmt.Sprintf(
-		"UPDATE %s SET status = 'accepted', updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND friend_id = $2",
+		"UPDATE %s SET status = 'accepted', updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND friend_id = $2 AND status = 'pending'",
 		fr.tableName,
 	)
 	result, err := tx.Exec(c, query, id, userId)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("friend request not found or no permission: id=%s, user=%s", id, userId)
+		return "", fmt.Errorf("friend request not found or no permission: id=%s, user=%s", id, userId)
 	}
 	var senderID string
 	querySelect := fmt.Sprintf(
@@ -160,7 +160,7 @@ func (fr *FriendRepository) Accept(c context.Context, userId string, id string)
 	)
 	err = tx.QueryRow(c, querySelect, id).Scan(&senderID)
 	if err != nil {
-		return fmt.Errorf("failed to get sender: %w", err)
+		return "", fmt.Errorf("failed to get sender: %w", err)
 	}
 	queryInsert := fmt.Sprintf(
 		"INSERT INTO %s (id, user_id, friend_id, status, created_at, updated_at) VALUES ($1, $2, $3, 'accepted', CURRENT_TIMESTAMP AT TIME ZONE 'UTC', CURRENT_TIMESTAMP AT TIME ZONE 'UTC') ON CONFLICT (user_id, friend_id) DO UPDATE SET status = 'accepted', updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE %s.user_id = $2 AND %s.friend_id = $3",
@@ -168,18 +168,26 @@ func (fr *FriendRepository) Accept(c context.Context, userId string, id string)
 	)
 	_, err = tx.Exec(c, queryInsert, ulid.Make().String(), userId, senderID)
 	if err != nil {
-		return fmt.Errorf("failed to insert reverse friend: %w", err)
+		return "", fmt.Errorf("failed to insert reverse friend: %w", err)
 	}
-	return tx.Commit(c)
+	return senderID, tx.Commit(c)
 }
 
-func (fr *FriendRepository) GetPending(c context.Context, userId string) ([]*entity.PendingFriend, error) {
+// GetPending returns the requests received by userId, latest first.
+func (fr *FriendRepository) GetPending(c context.Context, userId string, limit int, offset int) ([]*entity.PendingFriend, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
 	query := fmt.Sprintf(
-		"SELECT f.id, u.id, u.name, u.tag FROM %s f JOIN %s u ON f.user_id = u.id WHERE f.friend_id = $1 AND f.status = 'pending' ORDER BY f.created_at DESC",
+		"SELECT f.id, u.id, u.name, u.tag, u.avatar_id FROM %s f JOIN %s u ON f.user_id = u.id WHERE f.friend_id = $1 AND f.status = 'pending' ORDER BY f.created_at DESC LIMIT $2 OFFSET $3",
 		fr.tableName,
 		fr.userTableName,
 	)
-	rows, err := fr.pool.Query(c, query, userId)
+	rows, err := fr.pool.Query(c, query, userId, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +196,7 @@ func (fr *FriendRepository) GetPending(c context.Context, userId string) ([]*ent
 	var pending []*entity.PendingFriend
 	for rows.Next() {
 		var pf entity.PendingFriend
-		err = rows.Scan(&pf.ID, &pf.SenderID, &pf.Name, &pf.Tag)
+		err = rows.Scan(&pf.ID, &pf.SenderID, &pf.Name, &pf.Tag, &pf.AvatarId)
 		if err != nil {
 			return nil, err
 		}
@@ -200,25 +208,103 @@ func (fr *FriendRepository) GetPending(c context.Context, userId string) ([]*ent
 	return pending, nil
 }
 
-func (fr *FriendRepository) AddFriend(c context.Context, userId string, friendId string) error {
+// GetOutgoing returns the requests sent by userId that are still pending,
+// latest first.
+func (fr *FriendRepository) GetOutgoing(c context.Context, userId string, limit int, offset int) ([]entity.OutgoingFriend, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := fmt.Sprintf(
+		"SELECT f.id, u.id, u.name, u.tag, u.avatar_id, f.updated_at FROM %s f JOIN %s u ON f.friend_id = u.id WHERE f.user_id = $1 AND f.status = 'pending' ORDER BY f.updated_at DESC LIMIT $2 OFFSET $3",
+		fr.tableName,
+		fr.userTableName,
+	)
+	rows, err := fr.pool.Query(c, query, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outgoing []entity.OutgoingFriend
+	for rows.Next() {
+		var of entity.OutgoingFriend
+		err = rows.Scan(&of.ID, &of.RecipientID, &of.Name, &of.Tag, &of.AvatarId, &of.SentAt)
+		if err != nil {
+			return nil, err
+		}
+		outgoing = append(outgoing, of)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return outgoing, nil
+}
+
+// Cancel takes back the pending request with the given id sent by userId and
+// returns the id of its recipient.
+func (fr *FriendRepository) Cancel(c context.Context, userId string, id string) (string, error) {
+	var recipientId string
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND user_id = $2 AND status = 'pending' RETURNING friend_id",
+		fr.tableName,
+	)
+	err := fr.pool.QueryRow(c, query, id, userId).Scan(&recipientId)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("friend request not found or no permission: id=%s, user=%s", id, userId)
+	}
+	if err != nil {
+		return "", err
+	}
+	return recipientId, nil
+}
+
+// ExpirePending removes up to limit pending requests last sent before the
+// given time and returns how many were removed.
+func (fr *FriendRepository) ExpirePending(c context.Context, before time.Time, limit int) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE status = 'pending' AND updated_at < $1 LIMIT $2)",
+		fr.tableName,
+	)
+	result, err := fr.pool.Exec(c, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// AddFriend sends a friend request from userId to friendId, reusing a request
+// that was declined before, and returns its id.
+func (fr *FriendRepository) AddFriend(c context.Context, userId string, friendId string) (string, error) {
 	tx, err := fr.pool.Begin(c)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer tx.Rollback(c)
 
 	parsedUserId := ulid.MustParse(userId)
 	parsedFriendId := ulid.MustParse(friendId)
 
-	var existingStatus string
+	blocked, err := isBlocked(c, tx, fr.tableName, userId, friendId)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return "", ErrBlocked
+	}
+
+	var existingId, existingStatus string
 	query := fmt.Sprintf(
-		"SELECT status FROM %s WHERE user_id = $1 AND friend_id = $2",
+		"SELECT id, status FROM %s WHERE user_id = $1 AND friend_id = $2",
 		fr.tableName,
 	)
-	err = tx.QueryRow(c, query, parsedUserId.String(), parsedFriendId.String()).Scan(&existingStatus)
+	err = tx.QueryRow(c, query, parsedUserId.String(), parsedFriendId.String()).Scan(&existingId, &existingStatus)
 	if err == nil {
 		if existingStatus == "pending" || existingStatus == "accepted" {
-			return fmt.Errorf("request already exists: status %s", existingStatus)
+			return "", fmt.Errorf("request already exists: status %s", existingStatus)
 		} else if existingStatus == "rejected" {
 			query = fmt.Sprintf(""+
 				"UPDATE %s SET status = 'pending', updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE user_id = $1 AND friend_id = $2",
@@ -226,12 +312,12 @@ func (fr *FriendRepository) AddFriend(c context.Context, userId string, friendId
 			)
 			_, err = tx.Exec(c, query, parsedUserId.String(), parsedFriendId.String())
 			if err != nil {
-				return err
+				return "", err
 			}
-			return tx.Commit(c)
+			return existingId, tx.Commit(c)
 		}
 	} else if err != pgx.ErrNoRows {
-		return err
+		return "", err
 	}
 
 	newFriend := entity.NewFriend(entity.Friend{
@@ -252,7 +338,133 @@ func (fr *FriendRepository) AddFriend(c context.Context, userId string, friendId
 		newFriend.Status,
 	)
 	if err != nil {
+		return "", err
+	}
+	return newFriend.Id.String(), tx.Commit(c)
+}
+
+func (fr *FriendRepository) AreFriends(c context.Context, userId string, friendId string) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(
+		"SELECT EXISTS (SELECT 1 FROM %s WHERE user_id = $1 AND friend_id = $2 AND status = 'accepted')",
+		fr.tableName,
+	)
+	err := fr.pool.QueryRow(c, query, userId, friendId).Scan(&exists)
+	return exists, err
+}
+
+// ListIds returns the ids of all accepted friends of the user.
+func (fr *FriendRepository) ListIds(c context.Context, userId string) ([]string, error) {
+	query := fmt.Sprintf(
+		"SELECT friend_id FROM %s WHERE user_id = $1 AND status = 'accepted'",
+		fr.tableName,
+	)
+	rows, err := fr.pool.Query(c, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Block ends any friendship or pending request between the users and keeps
+// blockedId from contacting userId until Unblock. A block the other user
+// placed in turn is left alone.
+func (fr *FriendRepository) Block(c context.Context, userId string, blockedId string) error {
+	tx, err := fr.pool.Begin(c)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(c)
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE user_id = $1 AND friend_id = $2 AND status <> 'blocked'",
+		fr.tableName,
+	)
+	if _, err = tx.Exec(c, query, blockedId, userId); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %[1]s (id, user_id, friend_id, status) VALUES ($1, $2, $3, 'blocked') ON CONFLICT (user_id, friend_id) DO UPDATE SET status = 'blocked', updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC'",
+		fr.tableName,
+	)
+	if _, err = tx.Exec(c, query, ulid.Make().String(), userId, blockedId); err != nil {
 		return err
 	}
+
 	return tx.Commit(c)
 }
+
+func (fr *FriendRepository) Unblock(c context.Context, userId string, blockedId string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE user_id = $1 AND friend_id = $2 AND status = 'blocked'",
+		fr.tableName,
+	)
+	result, err := fr.pool.Exec(c, query, userId, blockedId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user is not blocked: user=%s, blocked=%s", userId, blockedId)
+	}
+	return nil
+}
+
+// ListBlocked returns the users blocked by userId, latest first.
+func (fr *FriendRepository) ListBlocked(c context.Context, userId string) ([]entity.FriendUser, error) {
+	query := fmt.Sprintf(
+		"SELECT u.id, u.name, u.tag, u.avatar_id FROM %s f JOIN %s u ON f.friend_id = u.id WHERE f.user_id = $1 AND f.status = 'blocked' ORDER BY f.updated_at DESC",
+		fr.tableName,
+		fr.userTableName,
+	)
+	rows, err := fr.pool.Query(c, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocked []entity.FriendUser
+	for rows.Next() {
+		var fu entity.FriendUser
+		if err = rows.Scan(&fu.Id, &fu.Name, &fu.Tag, &fu.AvatarId); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, fu)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return blocked, nil
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (fr *FriendRepository) IsBlocked(c context.Context, userId string, otherId string) (bool, error) {
+	return isBlocked(c, fr.pool, fr.tableName, userId, otherId)
+}
+
+type queryRower interface {
+	QueryRow(c context.Context, sql string, args ...any) pgx.Row
+}
+
+func isBlocked(c context.Context, db queryRower, tableName string, userId string, otherId string) (bool, error) {
+	var blocked bool
+	query := fmt.Sprintf(
+		"SELECT EXISTS (SELECT 1 FROM %s WHERE status = 'blocked' AND ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)))",
+		tableName,
+	)
+	err := db.QueryRow(c, query, userId, otherId).Scan(&blocked)
+	return blocked, err
+}
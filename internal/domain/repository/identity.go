@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+type IdentityRepository struct {
+	pool      *pgxpool.Pool
+	tableName string
+}
+
+func NewIdentityRepository(pool *pgxpool.Pool) *IdentityRepository {
+	return &IdentityRepository{
+		pool:      pool,
+		tableName: identitiesTableName,
+	}
+}
+
+func (ir *IdentityRepository) GetByProviderSubject(c context.Context, provider string, subject string) (entity.Identity, error) {
+	var identity entity.Identity
+	query := fmt.Sprintf(
+		"SELECT id, user_id, provider, subject, email, created_at FROM %s WHERE provider = $1 AND subject = $2",
+		ir.tableName,
+	)
+	err := ir.pool.QueryRow(c, query, provider, subject).
+		Scan(&identity.Id, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		return entity.Identity{}, err
+	}
+	return identity, nil
+}
+
+func (ir *IdentityRepository) List(c context.Context, userId string) ([]entity.Identity, error) {
+	query := fmt.Sprintf(
+		"SELECT id, user_id, provider, subject, email, created_at FROM %s WHERE user_id = $1 ORDER BY created_at",
+		ir.tableName,
+	)
+	rows, err := ir.pool.Query(c, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []entity.Identity{}
+	for rows.Next() {
+		var identity entity.Identity
+		err = rows.Scan(&identity.Id, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (ir *IdentityRepository) Create(c context.Context, identity entity.Identity) (entity.Identity, error) {
+	newIdentity := entity.NewIdentity(identity)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, user_id, provider, subject, email) VALUES ($1, $2, $3, $4, $5)",
+		ir.tableName,
+	)
+	_, err := ir.pool.Exec(
+		c, query,
+		newIdentity.Id.String(), newIdentity.UserId.String(), newIdentity.Provider, newIdentity.Subject, newIdentity.Email,
+	)
+	if err != nil {
+		return entity.Identity{}, err
+	}
+	return newIdentity, nil
+}
+
+// CreateWithUser registers a new user together with the identity used to
+// sign up, so a failed link never leaves an account nobody can log into.
+func (ir *IdentityRepository) CreateWithUser(c context.Context, user entity.User, identity entity.Identity) (entity.User, error) {
+	tx, err := ir.pool.Begin(c)
+	if err != nil {
+		return entity.User{}, err
+	}
+	defer tx.Rollback(c)
+
+	newUser := entity.NewUser(user)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, name, tag, email, password, email_verified_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		userTableName,
+	)
+	_, err = tx.Exec(
+		c, query,
+		newUser.Id.String(), newUser.Name, newUser.Tag, newUser.Email, newUser.Password, newUser.EmailVerifiedAt,
+	)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	identity.UserId = newUser.Id
+	newIdentity := entity.NewIdentity(identity)
+	query = fmt.Sprintf(
+		"INSERT INTO %s (id, user_id, provider, subject, email) VALUES ($1, $2, $3, $4, $5)",
+		ir.tableName,
+	)
+	_, err = tx.Exec(
+		c, query,
+		newIdentity.Id.String(), newIdentity.UserId.String(), newIdentity.Provider, newIdentity.Subject, newIdentity.Email,
+	)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if err = tx.Commit(c); err != nil {
+		return entity.User{}, err
+	}
+	return newUser, nil
+}
+
+func (ir *IdentityRepository) Count(c context.Context, userId string) (int, error) {
+	var count int
+	query := fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE user_id = $1",
+		ir.tableName,
+	)
+	err := ir.pool.QueryRow(c, query, userId).Scan(&count)
+	return count, err
+}
+
+func (ir *IdentityRepository) Delete(c context.Context, userId string, id string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND user_id = $2",
+		ir.tableName,
+	)
+	result, err := ir.pool.Exec(c, query, id, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("identity not found or no permission: id=%s, user=%s", id, userId)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type LoginAttemptRepository struct {
+	rdb *redis.Client
+}
+
+func NewLoginAttemptRepository(rdb *redis.Client) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		rdb: rdb,
+	}
+}
+
+// RegisterFailure counts a failed attempt for key inside a sliding window that
+// restarts with every failure, and returns the number of failures so far.
+func (lar *LoginAttemptRepository) RegisterFailure(c context.Context, key string, window time.Duration) (int64, error) {
+	pipe := lar.rdb.TxPipeline()
+	incr := pipe.Incr(c, loginFailuresKey(key))
+	pipe.Expire(c, loginFailuresKey(key), window)
+	if _, err := pipe.Exec(c); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (lar *LoginAttemptRepository) Lock(c context.Context, key string, ttl time.Duration) error {
+	return lar.rdb.Set(c, loginLockKey(key), 1, ttl).Err()
+}
+
+// LockedFor returns how long the key stays locked, or zero when it is not.
+func (lar *LoginAttemptRepository) LockedFor(c context.Context, key string) (time.Duration, error) {
+	ttl, err := lar.rdb.PTTL(c, loginLockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (lar *LoginAttemptRepository) Reset(c context.Context, key string) error {
+	return lar.rdb.Del(c, loginFailuresKey(key), loginLockKey(key)).Err()
+}
+
+func loginFailuresKey(key string) string {
+	return fmt.Sprintf("login_failures:%s", key)
+}
+
+func loginLockKey(key string) string {
+	return fmt.Sprintf("login_lock:%s", key)
+}
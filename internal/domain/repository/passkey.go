@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+type PasskeyRepository struct {
+	pool      *pgxpool.Pool
+	tableName string
+}
+
+func NewPasskeyRepository(pool *pgxpool.Pool) *PasskeyRepository {
+	return &PasskeyRepository{
+		pool:      pool,
+		tableName: webauthnCredentialsTableName,
+	}
+}
+
+func (pr *PasskeyRepository) Create(c context.Context, passkey entity.Passkey) (entity.Passkey, error) {
+	newPasskey := entity.NewPasskey(passkey)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, user_id, name, credential_id, credential) VALUES ($1, $2, $3, $4, $5) RETURNING created_at",
+		pr.tableName,
+	)
+	err := pr.pool.QueryRow(
+		c, query,
+		newPasskey.Id.String(), newPasskey.UserId.String(), newPasskey.Name, newPasskey.CredentialId, newPasskey.Credential,
+	).Scan(&newPasskey.CreatedAt)
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+	return newPasskey, nil
+}
+
+func (pr *PasskeyRepository) List(c context.Context, userId string) ([]entity.Passkey, error) {
+	query := fmt.Sprintf(
+		"SELECT id, user_id, name, credential_id, credential, last_used_at, created_at FROM %s WHERE user_id = $1 ORDER BY created_at",
+		pr.tableName,
+	)
+	rows, err := pr.pool.Query(c, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []entity.Passkey{}
+	for rows.Next() {
+		var passkey entity.Passkey
+		err = rows.Scan(&passkey.Id, &passkey.UserId, &passkey.Name, &passkey.CredentialId, &passkey.Credential, &passkey.LastUsedAt, &passkey.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return passkeys, nil
+}
+
+func (pr *PasskeyRepository) Count(c context.Context, userId string) (int, error) {
+	var count int
+	query := fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE user_id = $1",
+		pr.tableName,
+	)
+	err := pr.pool.QueryRow(c, query, userId).Scan(&count)
+	return count, err
+}
+
+// Use stores the credential record updated by a login (sign counter, flags)
+// and records the time of use.
+func (pr *PasskeyRepository) Use(c context.Context, id string, credential []byte) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET credential = $1, last_used_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $2",
+		pr.tableName,
+	)
+	_, err := pr.pool.Exec(c, query, credential, id)
+	return err
+}
+
+func (pr *PasskeyRepository) Delete(c context.Context, userId string, id string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND user_id = $2",
+		pr.tableName,
+	)
+	result, err := pr.pool.Exec(c, query, id, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("passkey not found or no permission: id=%s, user=%s", id, userId)
+	}
+	return nil
+}
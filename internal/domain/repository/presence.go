@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+// PresenceRepository keeps presence in Redis. A connected user has an online
+// key that their socket heartbeats keep alive, so a crashed server cannot
+// leave anyone online for longer than the TTL. The picked status, custom
+// status and last-seen time are kept separately and survive reconnects.
+type PresenceRepository struct {
+	rdb *redis.Client
+}
+
+func NewPresenceRepository(rdb *redis.Client) *PresenceRepository {
+	return &PresenceRepository{
+		rdb: rdb,
+	}
+}
+
+// Heartbeat marks the user as connected for ttl and reports whether they
+// were offline before.
+func (pr *PresenceRepository) Heartbeat(c context.Context, userId string, ttl time.Duration) (bool, error) {
+	err := pr.rdb.SetArgs(c, presenceOnlineKey(userId), 1, redis.SetArgs{TTL: ttl, Get: true}).Err()
+	if err == redis.Nil {
+		return true, nil
+	}
+	return false, err
+}
+
+func (pr *PresenceRepository) SetOffline(c context.Context, userId string) error {
+	return pr.rdb.Del(c, presenceOnlineKey(userId)).Err()
+}
+
+func (pr *PresenceRepository) SetStatus(c context.Context, userId string, status entity.PresenceStatus) error {
+	return pr.rdb.Set(c, presenceStatusKey(userId), string(status), 0).Err()
+}
+
+// SetCustomStatus stores the custom status, which Redis drops by itself once
+// it expires. A nil status clears it.
+func (pr *PresenceRepository) SetCustomStatus(c context.Context, userId string, status *entity.CustomStatus) error {
+	if status == nil {
+		return pr.rdb.Del(c, presenceCustomKey(userId)).Err()
+	}
+
+	value, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if status.ExpiresAt != nil {
+		ttl = time.Until(*status.ExpiresAt)
+		if ttl <= 0 {
+			return pr.rdb.Del(c, presenceCustomKey(userId)).Err()
+		}
+	}
+	return pr.rdb.Set(c, presenceCustomKey(userId), value, ttl).Err()
+}
+
+func (pr *PresenceRepository) SetLastSeen(c context.Context, userId string, at time.Time) error {
+	return pr.rdb.Set(c, presenceLastSeenKey(userId), at.Unix(), 0).Err()
+}
+
+// Get returns the raw presence of each user: Status is the picked status
+// (online when none was picked) if they are connected and offline otherwise,
+// so invisible users still show up as invisible here.
+func (pr *PresenceRepository) Get(c context.Context, userIds []string) (map[string]entity.Presence, error) {
+	type commands struct {
+		online   *redis.IntCmd
+		status   *redis.StringCmd
+		custom   *redis.StringCmd
+		lastSeen *redis.StringCmd
+	}
+
+	cmds := make([]commands, len(userIds))
+	pipe := pr.rdb.Pipeline()
+	for i, userId := range userIds {
+		cmds[i] = commands{
+			online:   pipe.Exists(c, presenceOnlineKey(userId)),
+			status:   pipe.Get(c, presenceStatusKey(userId)),
+			custom:   pipe.Get(c, presenceCustomKey(userId)),
+			lastSeen: pipe.Get(c, presenceLastSeenKey(userId)),
+		}
+	}
+	if _, err := pipe.Exec(c); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	presences := make(map[string]entity.Presence, len(userIds))
+	for i, userId := range userIds {
+		presence := entity.Presence{UserId: userId, Status: entity.PresenceOffline}
+		if cmds[i].online.Val() > 0 {
+			presence.Status = entity.PresenceOnline
+			if status := cmds[i].status.Val(); status != "" {
+				presence.Status = entity.PresenceStatus(status)
+			}
+		}
+		if custom := cmds[i].custom.Val(); custom != "" {
+			var status entity.CustomStatus
+			if err := json.Unmarshal([]byte(custom), &status); err == nil {
+				presence.CustomStatus = &status.Text
+				presence.CustomStatusExpiresAt = status.ExpiresAt
+			}
+		}
+		if lastSeen, err := cmds[i].lastSeen.Int64(); err == nil {
+			at := time.Unix(lastSeen, 0).UTC()
+			presence.LastSeenAt = &at
+		}
+		presences[userId] = presence
+	}
+	return presences, nil
+}
+
+func presenceOnlineKey(userId string) string {
+	return fmt.Sprintf("presence:online:%s", userId)
+}
+
+func presenceStatusKey(userId string) string {
+	return fmt.Sprintf("presence:status:%s", userId)
+}
+
+func presenceCustomKey(userId string) string {
+	return fmt.Sprintf("presence:custom:%s", userId)
+}
+
+func presenceLastSeenKey(userId string) string {
+	return fmt.Sprintf("presence:last_seen:%s", userId)
+}
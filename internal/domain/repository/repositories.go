@@ -9,6 +9,14 @@ type Repositories struct {
 	UserRepository         *UserRepository
 	FriendRepository       *FriendRepository
 	ConversationRepository *ConversationRepository
+	TokenRepository        *TokenRepository
+	SessionRepository      *SessionRepository
+	LoginAttemptRepository *LoginAttemptRepository
+	IdentityRepository     *IdentityRepository
+	APITokenRepository     *APITokenRepository
+	BotRepository          *BotRepository
+	PasskeyRepository      *PasskeyRepository
+	PresenceRepository     *PresenceRepository
 }
 
 func NewRepositories(pool *pgxpool.Pool, rdb *redis.Client) *Repositories {
@@ -16,5 +24,13 @@ func NewRepositories(pool *pgxpool.Pool, rdb *redis.Client) *Repositories {
 		UserRepository:         NewUserRepository(pool),
 		FriendRepository:       NewFriendRepository(pool),
 		ConversationRepository: NewConversationRepository(pool, rdb),
+		TokenRepository:        NewTokenRepository(rdb),
+		SessionRepository:      NewSessionRepository(pool),
+		LoginAttemptRepository: NewLoginAttemptRepository(rdb),
+		IdentityRepository:     NewIdentityRepository(pool),
+		APITokenRepository:     NewAPITokenRepository(pool),
+		BotRepository:          NewBotRepository(pool),
+		PasskeyRepository:      NewPasskeyRepository(pool),
+		PresenceRepository:     NewPresenceRepository(rdb),
 	}
 }
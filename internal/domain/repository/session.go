@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+type SessionRepository struct {
+	pool      *pgxpool.Pool
+	tableName string
+}
+
+func NewSessionRepository(pool *pgxpool.Pool) *SessionRepository {
+	return &SessionRepository{
+		pool:      pool,
+		tableName: sessionsTableName,
+	}
+}
+
+func (sr *SessionRepository) Create(c context.Context, session entity.Session) (entity.Session, error) {
+	newSession := entity.NewSession(session)
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, user_id, device_name, user_agent, ip, refresh_token_hash) VALUES ($1, $2, $3, $4, $5, $6)",
+		sr.tableName,
+	)
+	_, err := sr.pool.Exec(
+		c, query,
+		newSession.Id.String(), newSession.UserId.String(), newSession.DeviceName, newSession.UserAgent, newSession.Ip, newSession.RefreshTokenHash,
+	)
+	if err != nil {
+		return entity.Session{}, err
+	}
+	return newSession, nil
+}
+
+func (sr *SessionRepository) GetById(c context.Context, id string) (entity.Session, error) {
+	var session entity.Session
+	query := fmt.Sprintf(
+		"SELECT id, user_id, device_name, user_agent, ip, refresh_token_hash, created_at, last_seen_at FROM %s WHERE id = $1",
+		sr.tableName,
+	)
+	err := sr.pool.QueryRow(c, query, id).
+		Scan(&session.Id, &session.UserId, &session.DeviceName, &session.UserAgent, &session.Ip, &session.RefreshTokenHash, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return entity.Session{}, err
+	}
+	return session, nil
+}
+
+func (sr *SessionRepository) List(c context.Context, userId string) ([]entity.Session, error) {
+	query := fmt.Sprintf(
+		"SELECT id, user_id, device_name, user_agent, ip, refresh_token_hash, created_at, last_seen_at FROM %s WHERE user_id = $1 ORDER BY last_seen_at DESC",
+		sr.tableName,
+	)
+	rows, err := sr.pool.Query(c, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []entity.Session
+	for rows.Next() {
+		var session entity.Session
+		err = rows.Scan(&session.Id, &session.UserId, &session.DeviceName, &session.UserAgent, &session.Ip, &session.RefreshTokenHash, &session.CreatedAt, &session.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Rotate stores the new refresh token hash of the session, provided the
+// stored one still is previousHash. Otherwise a concurrent refresh with the
+// same token won, or the session is gone, and ErrSessionRotated is returned.
+func (sr *SessionRepository) Rotate(c context.Context, session entity.Session, previousHash string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET refresh_token_hash = $2, user_agent = $3, ip = $4, last_seen_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND refresh_token_hash = $5",
+		sr.tableName,
+	)
+	result, err := sr.pool.Exec(c, query, session.Id.String(), session.RefreshTokenHash, session.UserAgent, session.Ip, previousHash)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSessionRotated
+	}
+	return nil
+}
+
+func (sr *SessionRepository) Delete(c context.Context, userId string, id string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE id = $1 AND user_id = $2",
+		sr.tableName,
+	)
+	result, err := sr.pool.Exec(c, query, id, userId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("session not found or no permission: id=%s, user=%s", id, userId)
+	}
+	return nil
+}
+
+func (sr *SessionRepository) DeleteOthers(c context.Context, userId string, keepId string) ([]string, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE user_id = $1 AND id != $2 RETURNING id",
+		sr.tableName,
+	)
+	rows, err := sr.pool.Query(c, query, userId, keepId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (sr *SessionRepository) DeleteAll(c context.Context, userId string) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE user_id = $1",
+		sr.tableName,
+	)
+	_, err := sr.pool.Exec(c, query, userId)
+	return err
+}
@@ -5,4 +5,8 @@ const (
 	friendsTableName                  string = "friends"
 	conversationTableName             string = "conversations"
 	conversationParticipantsTableName string = "conversation_participants"
+	sessionsTableName                 string = "sessions"
+	identitiesTableName               string = "identities"
+	apiTokensTableName                string = "api_tokens"
+	webauthnCredentialsTableName      string = "webauthn_credentials"
 )
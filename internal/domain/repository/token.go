@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type TokenRepository struct {
+	rdb *redis.Client
+}
+
+func NewTokenRepository(rdb *redis.Client) *TokenRepository {
+	return &TokenRepository{
+		rdb: rdb,
+	}
+}
+
+func (tr *TokenRepository) RevokeToken(c context.Context, id string, ttl time.Duration) error {
+	return tr.rdb.Set(c, revokedTokenKey(id), 1, ttl).Err()
+}
+
+// RevokeUser revokes every token of the user issued up to the given time,
+// which is stored in milliseconds.
+func (tr *TokenRepository) RevokeUser(c context.Context, userId string, before time.Time, ttl time.Duration) error {
+	return tr.rdb.Set(c, revokedUserKey(userId), before.UnixMilli(), ttl).Err()
+}
+
+func (tr *TokenRepository) IsRevoked(c context.Context, userId string, issuedAt time.Time, ids ...string) (bool, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" {
+			keys = append(keys, revokedTokenKey(id))
+		}
+	}
+	if len(keys) > 0 {
+		n, err := tr.rdb.Exists(c, keys...).Result()
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+
+	before, err := tr.rdb.Get(c, revokedUserKey(userId)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	beforeMs, err := strconv.ParseInt(before, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.UnixMilli() <= beforeMs, nil
+}
+
+// StoreOneTime keeps a single-use token (already hashed by the caller) for
+// the given purpose, e.g. email verification or password reset.
+func (tr *TokenRepository) StoreOneTime(c context.Context, purpose string, hash string, value string, ttl time.Duration) error {
+	return tr.rdb.Set(c, oneTimeKey(purpose, hash), value, ttl).Err()
+}
+
+// ConsumeOneTime returns the value stored for the token and deletes it, so a
+// second call with the same token always fails.
+func (tr *TokenRepository) ConsumeOneTime(c context.Context, purpose string, hash string) (string, error) {
+	value, err := tr.rdb.GetDel(c, oneTimeKey(purpose, hash)).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("token not found or expired")
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// PeekOneTime returns the value stored for the token without consuming it.
+func (tr *TokenRepository) PeekOneTime(c context.Context, purpose string, hash string) (string, error) {
+	value, err := tr.rdb.Get(c, oneTimeKey(purpose, hash)).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("token not found or expired")
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// CountAttempt increments the counter stored under key and returns the new
+// value; the counter expires ttl after the first attempt.
+func (tr *TokenRepository) CountAttempt(c context.Context, key string, ttl time.Duration) (int64, error) {
+	redisKey := fmt.Sprintf("attempts:%s", key)
+	count, err := tr.rdb.Incr(c, redisKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		tr.rdb.Expire(c, redisKey, ttl)
+	}
+	return count, nil
+}
+
+// AcquireCooldown reports whether the key was free and reserves it for ttl.
+func (tr *TokenRepository) AcquireCooldown(c context.Context, key string, ttl time.Duration) (bool, error) {
+	return tr.rdb.SetNX(c, fmt.Sprintf("cooldown:%s", key), 1, ttl).Result()
+}
+
+// ScheduleExpiry records that member of the named set expires at the given
+// time. Scheduling a member again moves its expiry.
+func (tr *TokenRepository) ScheduleExpiry(c context.Context, set string, member string, at time.Time) error {
+	return tr.rdb.ZAdd(c, expiryKey(set), redis.Z{Score: float64(at.Unix()), Member: member}).Err()
+}
+
+// DueExpiries returns up to limit members of the named set that expired at or
+// before the given time, oldest first.
+func (tr *TokenRepository) DueExpiries(c context.Context, set string, before time.Time, limit int) ([]string, error) {
+	return tr.rdb.ZRangeByScore(c, expiryKey(set), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+}
+
+// RemoveExpiry forgets the given members of the named set.
+func (tr *TokenRepository) RemoveExpiry(c context.Context, set string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	values := make([]any, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+	return tr.rdb.ZRem(c, expiryKey(set), values...).Err()
+}
+
+func oneTimeKey(purpose string, hash string) string {
+	return fmt.Sprintf("one_time:%s:%s", purpose, hash)
+}
+
+func revokedTokenKey(id string) string {
+	return fmt.Sprintf("revoked_token:%s", id)
+}
+
+func revokedUserKey(userId string) string {
+	return fmt.Sprintf("revoked_user:%s", userId)
+}
+
+func expiryKey(set string) string {
+	return fmt.Sprintf("expiry:%s", set)
+}
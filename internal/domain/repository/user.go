@@ -5,20 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/neokofg/callap-backend/internal/domain/entity"
 )
 
 type UserRepository struct {
-	pool      *pgxpool.Pool
-	tableName string
+	pool             *pgxpool.Pool
+	tableName        string
+	friendsTableName string
 }
 
 func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
 	return &UserRepository{
-		pool:      pool,
-		tableName: userTableName,
+		pool:             pool,
+		tableName:        userTableName,
+		friendsTableName: friendsTableName,
 	}
 }
 
@@ -42,14 +46,82 @@ func (ur *UserRepository) GetByNameTag(c context.Context, nametag string) (entit
 	return user, nil
 }
 
+// Search finds users other than userId whose name starts with or resembles
+// name and whose tag starts with tagPrefix. Users who opted out of discovery
+// only show up for their friends, and users blocked either way never do.
+// Results come in the order described by entity.UserSearchRank and start
+// after the given cursor, if any.
+func (ur *UserRepository) Search(c context.Context, userId string, name string, tagPrefix string, after *entity.UserSearchCursor, limit int) ([]entity.UserSearchResult, error) {
+	query := fmt.Sprintf(`
+		WITH mine AS (
+			SELECT friend_id FROM %[2]s WHERE user_id = $1 AND status = 'accepted'
+		), candidates AS (
+			SELECT
+				u.id, u.name, u.tag, u.avatar_id,
+				u.id IN (SELECT friend_id FROM mine) AS is_friend,
+				(SELECT COUNT(*) FROM %[2]s f WHERE f.user_id = u.id AND f.status = 'accepted' AND f.friend_id IN (SELECT friend_id FROM mine)) AS mutual_friends,
+				CASE WHEN lower(u.name) = lower($2) THEN 2 WHEN lower(u.name) LIKE $3 THEN 1 ELSE 0 END AS match,
+				(similarity(lower(u.name), lower($2)) * 1000)::int AS score
+			FROM %[1]s u
+			WHERE u.id <> $1
+				AND u.deleted_at IS NULL
+				AND (lower(u.name) LIKE $3 OR lower(u.name) %% lower($2))
+				AND upper(u.tag) LIKE $4
+				AND (u.discoverable OR u.id IN (SELECT friend_id FROM mine))
+				AND NOT EXISTS (
+					SELECT 1 FROM %[2]s b
+					WHERE b.status = 'blocked' AND ((b.user_id = $1 AND b.friend_id = u.id) OR (b.user_id = u.id AND b.friend_id = $1))
+				)
+		), ranked AS (
+			SELECT *, CASE WHEN is_friend THEN 2 WHEN mutual_friends > 0 THEN 1 ELSE 0 END AS relation
+			FROM candidates
+		)
+		SELECT id, name, tag, avatar_id, is_friend, mutual_friends, relation, match, score
+		FROM ranked
+		WHERE $5 OR (-relation, -match, -score, id) > ($6, $7, $8, $9)
+		ORDER BY relation DESC, match DESC, score DESC, id
+		LIMIT $10`,
+		ur.tableName, ur.friendsTableName,
+	)
+
+	var cursor entity.UserSearchCursor
+	if after != nil {
+		cursor = *after
+	}
+	rows, err := ur.pool.Query(
+		c, query,
+		userId, name, escapeLike(strings.ToLower(name))+"%", escapeLike(strings.ToUpper(tagPrefix))+"%",
+		after == nil, -cursor.Rank.Relation, -cursor.Rank.Match, -cursor.Rank.Score, cursor.Id,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []entity.UserSearchResult
+	for rows.Next() {
+		var result entity.UserSearchResult
+		err = rows.Scan(&result.Id, &result.Name, &result.Tag, &result.AvatarId, &result.IsFriend, &result.MutualFriends, &result.Rank.Relation, &result.Rank.Match, &result.Rank.Score)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 func (ur *UserRepository) GetById(c context.Context, id string) (entity.User, error) {
 	var user entity.User
 	query := fmt.Sprintf(
-		"SELECT id, name, tag, email, password, created_at, updated_at, refresh_token FROM %s WHERE id = $1",
+		"SELECT id, name, tag, email, password, email_verified_at, pending_email, COALESCE(totp_secret, ''), totp_enabled_at, is_bot, bot_owner_id, deleted_at, name_changed_at, avatar_id, banner_id, discoverable, created_at, updated_at FROM %s WHERE id = $1",
 		ur.tableName,
 	)
 	err := ur.pool.QueryRow(c, query, id).
-		Scan(&user.Id, &user.Name, &user.Tag, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt, &user.RefreshToken)
+		Scan(&user.Id, &user.Name, &user.Tag, &user.Email, &user.Password, &user.EmailVerifiedAt, &user.PendingEmail, &user.TOTPSecret, &user.TOTPEnabledAt, &user.IsBot, &user.BotOwnerId, &user.DeletedAt, &user.NameChangedAt, &user.AvatarId, &user.BannerId, &user.Discoverable, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return entity.User{}, err
 	}
@@ -59,11 +131,11 @@ func (ur *UserRepository) GetById(c context.Context, id string) (entity.User, er
 func (ur *UserRepository) GetByEmail(c context.Context, email string) (entity.User, error) {
 	var user entity.User
 	query := fmt.Sprintf(
-		"SELECT id, name, tag, email, password, created_at, updated_at, refresh_token FROM %s WHERE email = $1",
+		"SELECT id, name, tag, email, password, email_verified_at, pending_email, COALESCE(totp_secret, ''), totp_enabled_at, is_bot, bot_owner_id, deleted_at, name_changed_at, avatar_id, banner_id, discoverable, created_at, updated_at FROM %s WHERE email = $1",
 		ur.tableName,
 	)
 	err := ur.pool.QueryRow(c, query, email).
-		Scan(&user.Id, &user.Name, &user.Tag, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt, &user.RefreshToken)
+		Scan(&user.Id, &user.Name, &user.Tag, &user.Email, &user.Password, &user.EmailVerifiedAt, &user.PendingEmail, &user.TOTPSecret, &user.TOTPEnabledAt, &user.IsBot, &user.BotOwnerId, &user.DeletedAt, &user.NameChangedAt, &user.AvatarId, &user.BannerId, &user.Discoverable, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return entity.User{}, err
 	}
@@ -88,15 +160,225 @@ func (ur *UserRepository) Create(c context.Context, user entity.User) (entity.Us
 
 func (ur *UserRepository) Update(c context.Context, user entity.User) (entity.User, error) {
 	query := fmt.Sprintf(
-		"UPDATE %s SET email = $2, tag = $3, name = $4, password = $5, refresh_token = $6 WHERE id = $1",
+		"UPDATE %s SET email = $2, tag = $3, name = $4, password = $5 WHERE id = $1",
 		ur.tableName,
 	)
 	_, err := ur.pool.Exec(
 		c, query,
-		user.Id.String(), user.Email, user.Tag, user.Name, user.Password, user.RefreshToken,
+		user.Id.String(), user.Email, user.Tag, user.Name, user.Password,
 	)
 	if err != nil {
 		return entity.User{}, err
 	}
 	return user, nil
 }
+
+// UpdateProfile stores the name, tag, pending email and discoverability of
+// the user. A new name or tag is stamped in name_changed_at. The email itself
+// only changes through ConfirmEmailChange.
+func (ur *UserRepository) UpdateProfile(c context.Context, user entity.User) (entity.User, error) {
+	query := fmt.Sprintf(
+		`UPDATE %s SET
+			name = $2,
+			tag = $3,
+			pending_email = $4,
+			discoverable = $5,
+			name_changed_at = CASE WHEN name <> $2 OR tag <> $3 THEN CURRENT_TIMESTAMP AT TIME ZONE 'UTC' ELSE name_changed_at END,
+			updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+		WHERE id = $1
+		RETURNING name_changed_at, updated_at`,
+		ur.tableName,
+	)
+	err := ur.pool.QueryRow(c, query, user.Id.String(), user.Name, user.Tag, user.PendingEmail, user.Discoverable).
+		Scan(&user.NameChangedAt, &user.UpdatedAt)
+	if err != nil {
+		return entity.User{}, err
+	}
+	return user, nil
+}
+
+// ConfirmEmailChange makes the pending email of the user their verified email,
+// provided it is still the given one. It reports whether the email changed.
+func (ur *UserRepository) ConfirmEmailChange(c context.Context, id string, email string) (bool, error) {
+	query := fmt.Sprintf(
+		`UPDATE %s SET
+			email = pending_email,
+			pending_email = NULL,
+			email_verified_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC',
+			updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+		WHERE id = $1 AND pending_email = $2`,
+		ur.tableName,
+	)
+	result, err := ur.pool.Exec(c, query, id, email)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// SetAvatar points the user at a new avatar, or at none when avatarId is nil,
+// and returns the previous one so its files can be removed.
+func (ur *UserRepository) SetAvatar(c context.Context, id string, avatarId *string) (*string, error) {
+	return ur.setImage(c, "avatar_id", id, avatarId)
+}
+
+// SetBanner is SetAvatar for the profile banner.
+func (ur *UserRepository) SetBanner(c context.Context, id string, bannerId *string) (*string, error) {
+	return ur.setImage(c, "banner_id", id, bannerId)
+}
+
+func (ur *UserRepository) setImage(c context.Context, column string, id string, imageId *string) (*string, error) {
+	var previous *string
+	query := fmt.Sprintf(
+		"UPDATE %[1]s u SET %[2]s = $2, updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' FROM (SELECT %[2]s FROM %[1]s WHERE id = $1 FOR UPDATE) old WHERE u.id = $1 RETURNING old.%[2]s",
+		ur.tableName, column,
+	)
+	err := ur.pool.QueryRow(c, query, id, imageId).Scan(&previous)
+	return previous, err
+}
+
+func (ur *UserRepository) MarkEmailVerified(c context.Context, id string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET email_verified_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC', updated_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND email_verified_at IS NULL",
+		ur.tableName,
+	)
+	_, err := ur.pool.Exec(c, query, id)
+	return err
+}
+
+func (ur *UserRepository) SetTOTPSecret(c context.Context, id string, secret string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_secret = $2, totp_enabled_at = NULL, totp_recovery_codes = '{}' WHERE id = $1 AND totp_enabled_at IS NULL",
+		ur.tableName,
+	)
+	result, err := ur.pool.Exec(c, query, id, secret)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	return nil
+}
+
+func (ur *UserRepository) EnableTOTP(c context.Context, id string, recoveryCodes []string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_enabled_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC', totp_recovery_codes = $2 WHERE id = $1 AND totp_secret IS NOT NULL",
+		ur.tableName,
+	)
+	_, err := ur.pool.Exec(c, query, id, recoveryCodes)
+	return err
+}
+
+func (ur *UserRepository) DisableTOTP(c context.Context, id string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_secret = NULL, totp_enabled_at = NULL, totp_recovery_codes = '{}' WHERE id = $1",
+		ur.tableName,
+	)
+	_, err := ur.pool.Exec(c, query, id)
+	return err
+}
+
+func (ur *UserRepository) SetRecoveryCodes(c context.Context, id string, recoveryCodes []string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_recovery_codes = $2 WHERE id = $1",
+		ur.tableName,
+	)
+	_, err := ur.pool.Exec(c, query, id, recoveryCodes)
+	return err
+}
+
+func (ur *UserRepository) UseRecoveryCode(c context.Context, id string, recoveryCode string) (bool, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET totp_recovery_codes = array_remove(totp_recovery_codes, $2) WHERE id = $1 AND $2 = ANY(totp_recovery_codes)",
+		ur.tableName,
+	)
+	result, err := ur.pool.Exec(c, query, id, recoveryCode)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ScheduleDeletion flags the user as deleted and returns when it happened.
+// Calling it again keeps the original time so the grace period cannot be
+// extended.
+func (ur *UserRepository) ScheduleDeletion(c context.Context, id string) (time.Time, error) {
+	var deletedAt time.Time
+	query := fmt.Sprintf(
+		"UPDATE %s SET deleted_at = COALESCE(deleted_at, CURRENT_TIMESTAMP AT TIME ZONE 'UTC') WHERE id = $1 RETURNING deleted_at",
+		ur.tableName,
+	)
+	err := ur.pool.QueryRow(c, query, id).Scan(&deletedAt)
+	return deletedAt, err
+}
+
+func (ur *UserRepository) CancelDeletion(c context.Context, id string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET deleted_at = NULL WHERE id = $1",
+		ur.tableName,
+	)
+	_, err := ur.pool.Exec(c, query, id)
+	return err
+}
+
+// PurgeDeleted removes up to limit users flagged as deleted before the given
+// time and returns their ids and images. Their messages stay, with the sender
+// set to NULL by the foreign key.
+func (ur *UserRepository) PurgeDeleted(c context.Context, before time.Time, limit int) ([]entity.User, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE deleted_at < $1 LIMIT $2) RETURNING id, avatar_id, banner_id",
+		ur.tableName,
+	)
+	rows, err := ur.pool.Query(c, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		var user entity.User
+		if err = rows.Scan(&user.Id, &user.AvatarId, &user.BannerId); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FirstFreeTag returns the first tag, in the order of alphabet, that is not
+// yet paired with name, or "" when every tag of length three is taken.
+func (ur *UserRepository) FirstFreeTag(c context.Context, name string, alphabet string) (string, error) {
+	return firstFreeTag(c, ur.pool, ur.tableName, name, alphabet)
+}
+
+func firstFreeTag(c context.Context, db queryRower, tableName string, name string, alphabet string) (string, error) {
+	query := fmt.Sprintf(`
+		WITH alphabet AS (
+			SELECT i, substr($2, i, 1) AS ch FROM generate_series(1, length($2)) AS i
+		)
+		SELECT a.ch || b.ch || c.ch
+		FROM alphabet a, alphabet b, alphabet c
+		WHERE NOT EXISTS (SELECT 1 FROM %s WHERE name = $1 AND tag = a.ch || b.ch || c.ch)
+		ORDER BY a.i, b.i, c.i
+		LIMIT 1
+	`, tableName)
+
+	var tag string
+	err := db.QueryRow(c, query, name, alphabet).Scan(&tag)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return tag, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match itself literally in a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
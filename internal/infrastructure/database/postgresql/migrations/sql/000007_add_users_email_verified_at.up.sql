@@ -0,0 +1,5 @@
+-- Accounts that exist when the column is added count as verified. The epoch
+-- default only marks those rows, so running this file again changes nothing.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ DEFAULT 'epoch';
+ALTER TABLE users ALTER COLUMN email_verified_at SET DEFAULT NULL;
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at = 'epoch';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_identities_user_id;
+DROP TABLE IF EXISTS identities;
\ No newline at end of file
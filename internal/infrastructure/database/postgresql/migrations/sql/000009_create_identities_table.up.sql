@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS identities (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+    UNIQUE(provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities (user_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_bot_owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS bot_webhook_secret;
+ALTER TABLE users DROP COLUMN IF EXISTS bot_webhook_url;
+ALTER TABLE users DROP COLUMN IF EXISTS bot_owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
\ No newline at end of file
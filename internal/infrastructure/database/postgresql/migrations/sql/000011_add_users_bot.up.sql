@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot_owner_id VARCHAR(26) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot_webhook_url VARCHAR(2048);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot_webhook_secret VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_users_bot_owner_id ON users (bot_owner_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webauthn_credentials_user_id;
+DROP TABLE IF EXISTS webauthn_credentials;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+DELETE FROM messages WHERE sender_id IS NULL;
+ALTER TABLE messages ALTER COLUMN sender_id SET NOT NULL;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS banner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_id;
\ No newline at end of file
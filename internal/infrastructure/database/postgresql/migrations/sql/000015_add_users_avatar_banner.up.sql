@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_id VARCHAR(36);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banner_id VARCHAR(36);
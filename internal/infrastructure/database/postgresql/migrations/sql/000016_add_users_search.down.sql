@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_name_trgm;
+ALTER TABLE users DROP COLUMN IF EXISTS discoverable;
\ No newline at end of file
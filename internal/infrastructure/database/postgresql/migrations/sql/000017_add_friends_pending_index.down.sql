@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_friends_pending_updated_at;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_friends_pending_updated_at ON friends (updated_at) WHERE status = 'pending';
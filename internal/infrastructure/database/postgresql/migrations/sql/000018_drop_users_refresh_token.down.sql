@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_token VARCHAR(500);
+CREATE INDEX IF NOT EXISTS idx_users_refresh_token ON users(refresh_token);
\ No newline at end of file
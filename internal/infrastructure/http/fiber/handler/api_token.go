@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type APITokenHandler struct {
+	logger          *zap.Logger
+	apiTokenService *service.APITokenService
+}
+
+func NewAPITokenHandler(apiTokenService *service.APITokenService, logger *zap.Logger) *APITokenHandler {
+	return &APITokenHandler{
+		logger:          logger,
+		apiTokenService: apiTokenService,
+	}
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=255"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,max=16,dive,max=64"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,gte=1,lte=365"`
+}
+
+func (ath *APITokenHandler) Create(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ath.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &CreateAPITokenRequest{}
+
+	err := utils.ParseBody(c, ath.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ath.logger, req)
+	if err != nil {
+		return err
+	}
+
+	lifetime := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	plain, token, err := ath.apiTokenService.Create(c.Context(), userId, req.Name, req.Scopes, lifetime)
+	if errors.Is(err, service.ErrUnknownScope) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	if errors.Is(err, service.ErrTooManyAPITokens) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		ath.logger.Error("Failed to create api token", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create api token")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"token":     plain,
+		"api_token": response.NewAPIToken(token),
+	}))
+}
+
+func (ath *APITokenHandler) List(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ath.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	tokens, err := ath.apiTokenService.List(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewAPITokens(tokens)))
+}
+
+func (ath *APITokenHandler) Scopes(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(service.APITokenScopes))
+}
+
+type RevokeAPITokenRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+func (ath *APITokenHandler) Revoke(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ath.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &RevokeAPITokenRequest{}
+
+	err := utils.ParseBody(c, ath.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ath.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = ath.apiTokenService.Revoke(c.Context(), userId, req.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
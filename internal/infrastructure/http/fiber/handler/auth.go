@@ -1,6 +1,11 @@
 package handler
 
 import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/neokofg/callap-backend/internal/application/service"
 	"github.com/neokofg/callap-backend/internal/domain/entity"
@@ -11,29 +16,48 @@ import (
 )
 
 type AuthHandler struct {
-	logger          *zap.Logger
-	jwtService      *jwt.Service
-	userService     *service.UserService
-	passwordService *service.PasswordService
+	logger               *zap.Logger
+	jwtService           *jwt.Service
+	userService          *service.UserService
+	passwordService      *service.PasswordService
+	sessionService       *service.SessionService
+	verificationService  *service.VerificationService
+	passwordResetService *service.PasswordResetService
+	twoFactorService     *service.TwoFactorService
+	loginThrottleService *service.LoginThrottleService
+	websocketService     *service.WebsocketService
 }
 
 func NewAuthHandler(
 	jwtService *jwt.Service,
 	userService *service.UserService,
 	passwordService *service.PasswordService,
+	sessionService *service.SessionService,
+	verificationService *service.VerificationService,
+	passwordResetService *service.PasswordResetService,
+	twoFactorService *service.TwoFactorService,
+	loginThrottleService *service.LoginThrottleService,
+	websocketService *service.WebsocketService,
 	logger *zap.Logger,
 ) *AuthHandler {
 	return &AuthHandler{
-		logger:          logger,
-		jwtService:      jwtService,
-		userService:     userService,
-		passwordService: passwordService,
+		logger:               logger,
+		jwtService:           jwtService,
+		userService:          userService,
+		passwordService:      passwordService,
+		sessionService:       sessionService,
+		verificationService:  verificationService,
+		passwordResetService: passwordResetService,
+		twoFactorService:     twoFactorService,
+		loginThrottleService: loginThrottleService,
+		websocketService:     websocketService,
 	}
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,max=255"`
+	Email      string `json:"email" validate:"required,email,max=255"`
+	Password   string `json:"password" validate:"required,max=255"`
+	DeviceName string `json:"device_name" validate:"max=255"`
 }
 
 func (ah *AuthHandler) Login(c *fiber.Ctx) error {
@@ -49,54 +73,272 @@ func (ah *AuthHandler) Login(c *fiber.Ctx) error {
 		return err
 	}
 
+	lockedFor, err := ah.loginThrottleService.LockedFor(c.Context(), req.Email, c.IP())
+	if err != nil {
+		ah.logger.Error("Failed to check login lockout", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to login")
+	}
+	if lockedFor > 0 {
+		return tooManyAttempts(c, lockedFor)
+	}
+
 	user, err := ah.userService.GetByEmail(c.Context(), req.Email)
+	if err == nil {
+		err = ah.passwordService.CheckPassword(req.Password, user.Password)
+	}
 	if err != nil {
+		lockedFor, err = ah.loginThrottleService.RegisterFailure(c.Context(), req.Email, c.IP())
+		if err != nil {
+			ah.logger.Error("Failed to register login failure", zap.Error(err))
+		}
+		if lockedFor > 0 {
+			return tooManyAttempts(c, lockedFor)
+		}
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid password or email")
 	}
 
-	if err = ah.passwordService.CheckPassword(req.Password, user.Password); err != nil {
-		return fiber.NewError(fiber.StatusUnauthorized, "Invalid password or email")
+	if ah.passwordService.NeedsRehash(user.Password) {
+		ah.rehashPassword(c, user, req.Password)
+	}
+
+	// With two-factor authentication on, the failures are only cleared once
+	// the code is right as well; see LoginTwoFactor.
+	if user.TOTPEnabledAt != nil {
+		return twoFactorChallenge(c, ah.logger, ah.twoFactorService, user, req.DeviceName)
 	}
 
-	body := map[string]interface{}{
-		"name":       user.Name,
-		"tag":        user.Tag,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
+	if err = ah.loginThrottleService.RegisterSuccess(c.Context(), req.Email); err != nil {
+		ah.logger.Error("Failed to reset login failures", zap.Error(err))
 	}
 
-	token, err := ah.jwtService.GenerateToken(user.Id, body)
+	return startSession(c, ah.logger, ah.sessionService, user, req.DeviceName)
+}
+
+// rehashPassword upgrades a stored hash to the configured algorithm while the
+// plain password is at hand. Failures are logged only: the login itself is valid.
+func (ah *AuthHandler) rehashPassword(c *fiber.Ctx, user entity.User, password string) {
+	hashedPassword, err := ah.passwordService.HashPassword(password)
 	if err != nil {
-		ah.logger.Error("Failed to generate token", zap.Error(err))
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate token")
+		ah.logger.Error("Failed to rehash password", zap.Error(err), zap.String("userId", user.Id.String()))
+		return
 	}
-	refreshBody := map[string]interface{}{
-		"type": "refresh",
+
+	user.Password = hashedPassword
+	if _, err = ah.userService.Update(c.Context(), user); err != nil {
+		ah.logger.Error("Failed to store rehashed password", zap.Error(err), zap.String("userId", user.Id.String()))
 	}
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=255"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+func (ah *AuthHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	req := &LoginTwoFactorRequest{}
 
-	refreshToken, err := ah.jwtService.GenerateToken(user.Id, refreshBody)
+	err := utils.ParseBody(c, ah.logger, req)
 	if err != nil {
-		ah.logger.Error("Failed to generate refresh token", zap.Error(err))
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate refresh token")
+		return err
 	}
-	user.RefreshToken = refreshToken
-	_, err = ah.userService.Update(c.Context(), user)
+
+	err = validator.Validate(ah.logger, req)
 	if err != nil {
-		ah.logger.Error("Failed to update user", zap.Error(err))
-		return fiber.NewError(fiber.StatusUnprocessableEntity, "Failed to update user")
+		return err
+	}
+
+	user, err := ah.twoFactorService.ChallengeUser(c.Context(), req.ChallengeToken)
+	if errors.Is(err, service.ErrInvalidTwoFactorToken) {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		ah.logger.Error("Failed to resolve two-factor challenge", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to login")
+	}
+
+	// Wrong codes count as failed logins of the account, so opening new
+	// challenges does not give an attacker who knows the password more guesses.
+	lockedFor, err := ah.loginThrottleService.LockedFor(c.Context(), user.Email, c.IP())
+	if err != nil {
+		ah.logger.Error("Failed to check login lockout", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to login")
+	}
+	if lockedFor > 0 {
+		return tooManyAttempts(c, lockedFor)
+	}
+
+	_, deviceName, err := ah.twoFactorService.ResolveChallenge(c.Context(), req.ChallengeToken, req.Code)
+	if errors.Is(err, service.ErrInvalidTwoFactorCode) {
+		lockedFor, err = ah.loginThrottleService.RegisterFailure(c.Context(), user.Email, c.IP())
+		if err != nil {
+			ah.logger.Error("Failed to register login failure", zap.Error(err))
+		}
+		if lockedFor > 0 {
+			return tooManyAttempts(c, lockedFor)
+		}
+		return fiber.NewError(fiber.StatusUnauthorized, service.ErrInvalidTwoFactorCode.Error())
+	}
+	if errors.Is(err, service.ErrInvalidTwoFactorToken) {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		ah.logger.Error("Failed to resolve two-factor challenge", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to login")
+	}
+
+	if err = ah.loginThrottleService.RegisterSuccess(c.Context(), user.Email); err != nil {
+		ah.logger.Error("Failed to reset login failures", zap.Error(err))
+	}
+
+	return startSession(c, ah.logger, ah.sessionService, user, deviceName)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (ah *AuthHandler) Refresh(c *fiber.Ctx) error {
+	req := &RefreshRequest{}
+
+	err := utils.ParseBody(c, ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	token, err := ah.jwtService.ValidateToken(req.RefreshToken)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	if tokenType, ok := token.Body["type"].(string); !ok || tokenType != "refresh" {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	sessionId, ok := token.Body["sid"].(string)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	revoked, err := ah.jwtService.IsRevoked(c.Context(), token)
+	if err != nil {
+		ah.logger.Error("Failed to check token revocation", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to refresh token")
+	}
+	if revoked {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	session, err := ah.sessionService.GetById(c.Context(), sessionId)
+	if err != nil || session.UserId != token.UserId {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	if session.RefreshTokenHash != ah.sessionService.HashToken(req.RefreshToken) {
+		return ah.refreshTokenReused(c, session)
+	}
+
+	user, err := ah.userService.GetById(c.Context(), token.UserId.String())
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	session.UserAgent = userAgent(c)
+	session.Ip = c.IP()
+	tokens, err := ah.sessionService.Renew(c.Context(), user, session)
+	if errors.Is(err, service.ErrRefreshTokenReused) {
+		// Another refresh with the same token got there first.
+		return ah.refreshTokenReused(c, session)
+	}
+	if err != nil {
+		ah.logger.Error("Failed to rotate session", zap.Error(err))
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(tokens))
+}
+
+// refreshTokenReused handles a validly signed refresh token that is no longer
+// the stored one: it has already been rotated, so it was either replayed or
+// stolen. The whole session is dropped, forcing every holder of the family to
+// log in again.
+func (ah *AuthHandler) refreshTokenReused(c *fiber.Ctx, session entity.Session) error {
+	ah.logger.Warn("Refresh token reuse detected", zap.String("userId", session.UserId.String()), zap.String("sessionId", session.Id.String()))
+	if err := ah.sessionService.Revoke(c.Context(), session.UserId.String(), session.Id.String()); err != nil {
+		ah.logger.Error("Failed to revoke session", zap.Error(err))
+	}
+	return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+}
+
+func (ah *AuthHandler) Logout(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ah.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+	token, exists := c.Locals("token").(jwt.Token)
+	if !exists {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	if err := ah.jwtService.InvalidateToken(c.Context(), token); err != nil {
+		ah.logger.Error("Failed to invalidate token", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to logout")
+	}
+
+	if sessionId, ok := token.Body["sid"].(string); ok {
+		if err := ah.sessionService.Revoke(c.Context(), userId, sessionId); err != nil {
+			ah.logger.Error("Failed to revoke session", zap.Error(err))
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to logout")
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func (ah *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ah.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
-		"access_token":  token,
-		"refresh_token": refreshToken,
-	}))
+	if err := ah.sessionService.RevokeAll(c.Context(), userId); err != nil {
+		ah.logger.Error("Failed to revoke sessions", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to logout")
+	}
+
+	ah.websocketService.Disconnect(userId)
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func tooManyAttempts(c *fiber.Ctx, retryAfter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"success": false,
+		"message": "Too many login attempts, try again later",
+	})
+}
+
+func userAgent(c *fiber.Ctx) string {
+	ua := c.Get(fiber.HeaderUserAgent)
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+	return ua
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name" validate:"required,min=3,max=32"`
-	Password string `json:"password" validate:"required,min=8,max=32"`
-	Tag      string `json:"tag" validate:"required,min=3,max=3"`
-	Email    string `json:"email" validate:"required,email,max=255"`
+	Name       string `json:"name" validate:"required,min=3,max=32"`
+	Password   string `json:"password" validate:"required,min=8,max=32"`
+	Tag        string `json:"tag" validate:"omitempty,min=3,max=3"`
+	Email      string `json:"email" validate:"required,email,max=255"`
+	DeviceName string `json:"device_name" validate:"max=255"`
 }
 
 func (ah *AuthHandler) Register(c *fiber.Ctx) error {
@@ -124,53 +366,169 @@ func (ah *AuthHandler) Register(c *fiber.Ctx) error {
 	}
 
 	user, err = ah.userService.Create(c.Context(), user)
-	if err != nil {
+	switch {
+	case errors.Is(err, service.ErrNameTagTaken):
+		return registrationConflict(c, "tag", err)
+	case errors.Is(err, service.ErrNoFreeTag):
+		return registrationConflict(c, "name", err)
+	case errors.Is(err, service.ErrEmailTaken):
+		return registrationConflict(c, "email", err)
+	case err != nil:
 		ah.logger.Error("Failed to create user", zap.Error(err))
-		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
-			"success": false,
-			"errors":  err.Error(),
-		})
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create user")
+	}
+
+	if err = ah.verificationService.SendEmailVerification(c.Context(), user); err != nil {
+		ah.logger.Error("Failed to send verification email", zap.Error(err), zap.String("userId", user.Id.String()))
+	}
+
+	return startSession(c, ah.logger, ah.sessionService, user, req.DeviceName)
+}
+
+// registrationConflict reports which field of the registration clashes with
+// an existing account, so clients can point at it or suggest another value.
+func registrationConflict(c *fiber.Ctx, field string, err error) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"success": false,
+		"field":   field,
+		"message": err.Error(),
+	})
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=255"`
+}
+
+func (ah *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	req := &VerifyEmailRequest{}
+
+	err := utils.ParseBody(c, ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	_, err = ah.verificationService.VerifyEmail(c.Context(), req.Token)
+	if errors.Is(err, service.ErrInvalidVerificationToken) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	if errors.Is(err, service.ErrEmailTaken) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		ah.logger.Error("Failed to verify email", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to verify email")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func (ah *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ah.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	user, err := ah.userService.GetById(c.Context(), userId)
+	if err != nil {
+		ah.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
 	}
 
-	body := map[string]interface{}{
-		"name":       user.Name,
-		"tag":        user.Tag,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
+	err = ah.verificationService.SendEmailVerification(c.Context(), user)
+	if errors.Is(err, service.ErrEmailAlreadyVerified) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
 	}
+	if errors.Is(err, service.ErrVerificationCooldown) {
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	}
+	if err != nil {
+		ah.logger.Error("Failed to send verification email", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send verification email")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+func (ah *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	req := &ForgotPasswordRequest{}
+
+	err := utils.ParseBody(c, ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := ah.userService.GetByEmail(c.Context(), req.Email)
+	if err == nil {
+		if err = ah.passwordResetService.SendReset(c.Context(), user); err != nil {
+			ah.logger.Error("Failed to send password reset", zap.Error(err), zap.String("userId", user.Id.String()))
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=32"`
+}
+
+func (ah *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	req := &ResetPasswordRequest{}
 
-	token, err := ah.jwtService.GenerateToken(user.Id, body)
+	err := utils.ParseBody(c, ah.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ah.logger, req)
 	if err != nil {
-		ah.logger.Error("Failed to generate token", zap.Error(err))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"errors":  err.Error(),
-		})
+		return err
 	}
-	refreshBody := map[string]interface{}{
-		"type": "refresh",
+
+	userId, err := ah.passwordResetService.ConsumeReset(c.Context(), req.Token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
 	}
 
-	refreshToken, err := ah.jwtService.GenerateToken(user.Id, refreshBody)
+	user, err := ah.userService.GetById(c.Context(), userId)
 	if err != nil {
-		ah.logger.Error("Failed to generate refresh token", zap.Error(err))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"errors":  err.Error(),
-		})
+		ah.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
 	}
-	user.RefreshToken = refreshToken
-	_, err = ah.userService.Update(c.Context(), user)
+
+	user.Password, err = ah.passwordService.HashPassword(req.Password)
 	if err != nil {
+		return err
+	}
+	if _, err = ah.userService.Update(c.Context(), user); err != nil {
 		ah.logger.Error("Failed to update user", zap.Error(err))
-		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
-			"success": false,
-			"errors":  err.Error(),
-		})
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Failed to update user")
+	}
+
+	if err = ah.sessionService.RevokeAll(c.Context(), userId); err != nil {
+		ah.logger.Error("Failed to revoke sessions", zap.Error(err))
 	}
+	ah.websocketService.Disconnect(userId)
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
-		"access_token":  token,
-		"refresh_token": refreshToken,
-	}))
+func (ah *AuthHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(ah.jwtService.JWKS())
 }
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type BotHandler struct {
+	logger           *zap.Logger
+	botService       *service.BotService
+	websocketService *service.WebsocketService
+}
+
+func NewBotHandler(botService *service.BotService, websocketService *service.WebsocketService, logger *zap.Logger) *BotHandler {
+	return &BotHandler{
+		logger:           logger,
+		botService:       botService,
+		websocketService: websocketService,
+	}
+}
+
+type CreateBotRequest struct {
+	Name string `json:"name" validate:"required,min=3,max=32"`
+}
+
+func (bh *BotHandler) Create(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		bh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &CreateBotRequest{}
+
+	err := utils.ParseBody(c, bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	bot, token, err := bh.botService.Create(c.Context(), userId, req.Name)
+	if errors.Is(err, service.ErrTooManyBots) || errors.Is(err, service.ErrNoFreeTag) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	if err != nil {
+		bh.logger.Error("Failed to create bot", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create bot")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"bot":   response.NewBot(bot),
+		"token": token,
+	}))
+}
+
+func (bh *BotHandler) List(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		bh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	bots, err := bh.botService.List(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewBots(bots)))
+}
+
+type BotRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+func (bh *BotHandler) RegenerateToken(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		bh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &BotRequest{}
+
+	err := utils.ParseBody(c, bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	token, err := bh.botService.RegenerateToken(c.Context(), userId, req.Id)
+	if err != nil {
+		return err
+	}
+	bh.websocketService.Disconnect(req.Id)
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"token": token,
+	}))
+}
+
+type SetBotWebhookRequest struct {
+	Id  string `json:"id" validate:"required"`
+	URL string `json:"url" validate:"omitempty,url,max=2048"`
+}
+
+func (bh *BotHandler) SetWebhook(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		bh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &SetBotWebhookRequest{}
+
+	err := utils.ParseBody(c, bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	secret, err := bh.botService.SetWebhook(c.Context(), userId, req.Id, req.URL)
+	if errors.Is(err, service.ErrInvalidWebhookURL) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	if secret == "" {
+		return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+	}
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"secret": secret,
+	}))
+}
+
+func (bh *BotHandler) Delete(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		bh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &BotRequest{}
+
+	err := utils.ParseBody(c, bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(bh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = bh.botService.Delete(c.Context(), userId, req.Id)
+	if err != nil {
+		return err
+	}
+	bh.websocketService.Disconnect(req.Id)
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
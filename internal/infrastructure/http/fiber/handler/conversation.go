@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
 	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
 	"github.com/neokofg/callap-backend/pkg/validator"
 	"go.uber.org/zap"
@@ -50,7 +53,7 @@ func (ch *ConversationHandler) ListMessages(c *fiber.Ctx) error {
 		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(messages))
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewMessages(messages)))
 }
 
 type DeleteMessageRequest struct {
@@ -109,11 +112,14 @@ func (ch *ConversationHandler) NewMessage(c *fiber.Ctx) error {
 	}
 
 	msg, err := ch.conversationService.NewMessage(c.Context(), userId, req.Id, req.Content)
+	if errors.Is(err, service.ErrUserBlocked) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
 	if err != nil {
 		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(msg))
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewMessage(*msg)))
 }
 
 type HideConversationRequest struct {
@@ -174,7 +180,7 @@ func (ch *ConversationHandler) GetConversation(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(conv))
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewConversation(conv)))
 }
 
 func (ch *ConversationHandler) ListConversations(c *fiber.Ctx) error {
@@ -201,7 +207,7 @@ func (ch *ConversationHandler) ListConversations(c *fiber.Ctx) error {
 		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(convs))
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewConversationSummaries(convs)))
 }
 
 type GetOrCreateRequest struct {
@@ -228,6 +234,9 @@ func (ch *ConversationHandler) GetOrCreate(c *fiber.Ctx) error {
 	}
 
 	convId, err := ch.conversationService.GetOrCreate(c.Context(), userId, req.TargetId)
+	if errors.Is(err, service.ErrUserBlocked) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
 	if err != nil {
 		return err
 	}
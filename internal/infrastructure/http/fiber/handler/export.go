@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type ExportHandler struct {
+	logger        *zap.Logger
+	exportService *service.ExportService
+}
+
+func NewExportHandler(exportService *service.ExportService, logger *zap.Logger) *ExportHandler {
+	return &ExportHandler{
+		logger:        logger,
+		exportService: exportService,
+	}
+}
+
+func (eh *ExportHandler) Request(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		eh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	err := eh.exportService.Request(c.Context(), userId)
+	if errors.Is(err, service.ErrExportCooldown) {
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	}
+	if err != nil {
+		eh.logger.Error("Failed to request export", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to request export")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(utils.MakeSuccessResponse())
+}
+
+type DownloadExportRequest struct {
+	Token string `query:"token" validate:"required,max=255"`
+}
+
+// Download serves an archive to whoever holds its link, so it can be opened
+// straight from the browser without an access token.
+func (eh *ExportHandler) Download(c *fiber.Ctx) error {
+	req := &DownloadExportRequest{}
+
+	err := utils.ParseQuery(c, eh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(eh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	archive, err := eh.exportService.Open(c.Context(), req.Token)
+	if errors.Is(err, service.ErrInvalidExportLink) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		eh.logger.Error("Failed to open export", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to download export")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="callap-export.zip"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).SendStream(archive)
+}
@@ -1,24 +1,29 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
 	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
 	"github.com/neokofg/callap-backend/pkg/validator"
 	"go.uber.org/zap"
 )
 
 type FriendHandler struct {
-	logger        *zap.Logger
-	friendService *service.FriendService
-	userService   *service.UserService
+	logger          *zap.Logger
+	friendService   *service.FriendService
+	userService     *service.UserService
+	presenceService *service.PresenceService
 }
 
-func NewFriendHandler(friendService *service.FriendService, userService *service.UserService, logger *zap.Logger) *FriendHandler {
+func NewFriendHandler(friendService *service.FriendService, userService *service.UserService, presenceService *service.PresenceService, logger *zap.Logger) *FriendHandler {
 	return &FriendHandler{
-		logger:        logger,
-		friendService: friendService,
-		userService:   userService,
+		logger:          logger,
+		friendService:   friendService,
+		userService:     userService,
+		presenceService: presenceService,
 	}
 }
 
@@ -51,7 +56,16 @@ func (fh *FriendHandler) ListFriends(c *fiber.Ctx) error {
 		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(list))
+	friendIds := make([]string, 0, len(list))
+	for _, friend := range list {
+		friendIds = append(friendIds, friend.Id.String())
+	}
+	presences, err := fh.presenceService.List(c.Context(), friendIds)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewFriendsWithPresence(list, presences)))
 }
 
 type DeleteRequest struct {
@@ -144,6 +158,12 @@ func (fh *FriendHandler) Accept(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
 }
 
+// RequestListRequest pages through friend requests; limit defaults to 20.
+type RequestListRequest struct {
+	Limit  int `query:"limit" validate:"omitempty,gte=1,lte=100"`
+	Offset int `query:"offset" validate:"omitempty,gt=0"`
+}
+
 func (fh *FriendHandler) GetPending(c *fiber.Ctx) error {
 	userId, exists := c.Locals("userId").(string)
 	if !exists {
@@ -151,12 +171,83 @@ func (fh *FriendHandler) GetPending(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
 	}
 
-	pending, err := fh.friendService.GetPending(c.Context(), userId)
+	req := &RequestListRequest{}
+
+	err := utils.ParseQuery(c, fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	pending, err := fh.friendService.GetPending(c.Context(), userId, req.Limit, req.Offset)
 	if err != nil {
 		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(pending))
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewPendingFriends(pending)))
+}
+
+// GetOutgoing lists the requests the user sent that are still pending.
+func (fh *FriendHandler) GetOutgoing(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		fh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &RequestListRequest{}
+
+	err := utils.ParseQuery(c, fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	outgoing, err := fh.friendService.GetOutgoing(c.Context(), userId, req.Limit, req.Offset)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewOutgoingFriends(outgoing)))
+}
+
+type CancelRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+// Cancel takes back a request the user sent, by its id.
+func (fh *FriendHandler) Cancel(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		fh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &CancelRequest{}
+
+	err := utils.ParseBody(c, fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = fh.friendService.Cancel(c.Context(), userId, req.Id)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
 }
 
 type AddFriendRequest struct {
@@ -192,9 +283,89 @@ func (fh *FriendHandler) AddFriend(c *fiber.Ctx) error {
 	}
 
 	err = fh.friendService.AddFriend(c.Context(), userId, userFriend.Id.String())
+	if errors.Is(err, service.ErrUserBlocked) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+type BlockRequest struct {
+	UserId string `json:"user_id" validate:"required,len=26"`
+}
+
+// Block ends the friendship with the user, if any, and stops them from
+// sending friend requests and private messages.
+func (fh *FriendHandler) Block(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		fh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &BlockRequest{}
+
+	err := utils.ParseBody(c, fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fh.userService.GetById(c.Context(), req.UserId); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	err = fh.friendService.Block(c.Context(), userId, req.UserId)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func (fh *FriendHandler) Unblock(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		fh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &BlockRequest{}
+
+	err := utils.ParseBody(c, fh.logger, req)
 	if err != nil {
 		return err
 	}
 
+	err = validator.Validate(fh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = fh.friendService.Unblock(c.Context(), userId, req.UserId)
+	if err != nil {
+		return err
+	}
 	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
 }
+
+func (fh *FriendHandler) ListBlocked(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		fh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	blocked, err := fh.friendService.ListBlocked(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewFriends(blocked)))
+}
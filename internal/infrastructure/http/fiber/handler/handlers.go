@@ -11,14 +11,45 @@ type Handlers struct {
 	FriendHandler       *FriendHandler
 	ConversationHandler *ConversationHandler
 	WebsocketHandler    *WebsocketHandler
+	SessionHandler      *SessionHandler
+	TwoFactorHandler    *TwoFactorHandler
+	OAuthHandler        *OAuthHandler
+	APITokenHandler     *APITokenHandler
+	BotHandler          *BotHandler
+	MagicLinkHandler    *MagicLinkHandler
+	PasskeyHandler      *PasskeyHandler
+	ExportHandler       *ExportHandler
+	MediaHandler        *MediaHandler
+	PresenceHandler     *PresenceHandler
 }
 
 func NewHandlers(services *service.Services, logger *zap.Logger) *Handlers {
 	return &Handlers{
-		AuthHandler:         NewAuthHandler(services.JWT, services.UserService, services.PasswordService, logger),
-		UserHandler:         NewUserHandler(services.UserService, logger),
-		FriendHandler:       NewFriendHandler(services.FriendService, services.UserService, logger),
+		AuthHandler: NewAuthHandler(
+			services.JWT,
+			services.UserService,
+			services.PasswordService,
+			services.SessionService,
+			services.VerificationService,
+			services.PasswordResetService,
+			services.TwoFactorService,
+			services.LoginThrottleService,
+			services.WebsocketService,
+			logger,
+		),
+		UserHandler:         NewUserHandler(services.UserService, services.PasswordService, services.SessionService, services.ProfileService, services.AccountDeletionService, services.WebsocketService, logger),
+		FriendHandler:       NewFriendHandler(services.FriendService, services.UserService, services.PresenceService, logger),
 		ConversationHandler: NewConversationHandler(services.ConversationService, logger),
-		WebsocketHandler:    NewWebsocketHandler(services.WebsocketService, logger),
+		WebsocketHandler:    NewWebsocketHandler(services.WebsocketService, services.PresenceService, logger),
+		SessionHandler:      NewSessionHandler(services.SessionService, logger),
+		TwoFactorHandler:    NewTwoFactorHandler(services.UserService, services.PasswordService, services.TwoFactorService, logger),
+		OAuthHandler:        NewOAuthHandler(services.OAuthService, services.UserService, services.SessionService, services.TwoFactorService, logger),
+		APITokenHandler:     NewAPITokenHandler(services.APITokenService, logger),
+		BotHandler:          NewBotHandler(services.BotService, services.WebsocketService, logger),
+		MagicLinkHandler:    NewMagicLinkHandler(services.UserService, services.MagicLinkService, services.SessionService, services.TwoFactorService, logger),
+		PasskeyHandler:      NewPasskeyHandler(services.UserService, services.PasskeyService, services.SessionService, logger),
+		ExportHandler:       NewExportHandler(services.ExportService, logger),
+		MediaHandler:        NewMediaHandler(services.UserService, services.ProfileService, logger),
+		PresenceHandler:     NewPresenceHandler(services.PresenceService, logger),
 	}
 }
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type MagicLinkHandler struct {
+	logger           *zap.Logger
+	userService      *service.UserService
+	magicLinkService *service.MagicLinkService
+	sessionService   *service.SessionService
+	twoFactorService *service.TwoFactorService
+}
+
+func NewMagicLinkHandler(
+	userService *service.UserService,
+	magicLinkService *service.MagicLinkService,
+	sessionService *service.SessionService,
+	twoFactorService *service.TwoFactorService,
+	logger *zap.Logger,
+) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		logger:           logger,
+		userService:      userService,
+		magicLinkService: magicLinkService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+	}
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+func (mlh *MagicLinkHandler) Send(c *fiber.Ctx) error {
+	req := &MagicLinkRequest{}
+
+	err := utils.ParseBody(c, mlh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(mlh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := mlh.userService.GetByEmail(c.Context(), req.Email)
+	if err == nil && !user.IsBot {
+		if err = mlh.magicLinkService.SendLink(c.Context(), user); err != nil {
+			mlh.logger.Error("Failed to send magic link", zap.Error(err), zap.String("userId", user.Id.String()))
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+type VerifyMagicLinkRequest struct {
+	Token      string `json:"token" validate:"required,max=255"`
+	DeviceName string `json:"device_name" validate:"max=255"`
+}
+
+func (mlh *MagicLinkHandler) Verify(c *fiber.Ctx) error {
+	req := &VerifyMagicLinkRequest{}
+
+	err := utils.ParseBody(c, mlh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(mlh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := mlh.magicLinkService.ConsumeLink(c.Context(), req.Token)
+	if errors.Is(err, service.ErrInvalidMagicLink) {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		mlh.logger.Error("Failed to consume magic link", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to login")
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return twoFactorChallenge(c, mlh.logger, mlh.twoFactorService, user, req.DeviceName)
+	}
+
+	return startSession(c, mlh.logger, mlh.sessionService, user, req.DeviceName)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxImageUpload stays below the default body limit of fiber so oversized
+	// files get a clear error instead of a dropped connection.
+	maxImageUpload = 4<<20 - 64<<10
+	// imageTimeout bounds a whole upload or removal: decoding, resizing and
+	// the storage writes are the slowest calls of the API.
+	imageTimeout = time.Minute
+)
+
+type MediaHandler struct {
+	logger         *zap.Logger
+	userService    *service.UserService
+	profileService *service.ProfileService
+}
+
+func NewMediaHandler(userService *service.UserService, profileService *service.ProfileService, logger *zap.Logger) *MediaHandler {
+	return &MediaHandler{
+		logger:         logger,
+		userService:    userService,
+		profileService: profileService,
+	}
+}
+
+// UploadAvatar takes the image in the "file" field of a multipart form.
+func (mh *MediaHandler) UploadAvatar(c *fiber.Ctx) error {
+	return mh.upload(c, mh.profileService.SetAvatar)
+}
+
+func (mh *MediaHandler) DeleteAvatar(c *fiber.Ctx) error {
+	return mh.remove(c, mh.profileService.RemoveAvatar)
+}
+
+// UploadBanner takes the image in the "file" field of a multipart form.
+func (mh *MediaHandler) UploadBanner(c *fiber.Ctx) error {
+	return mh.upload(c, mh.profileService.SetBanner)
+}
+
+func (mh *MediaHandler) DeleteBanner(c *fiber.Ctx) error {
+	return mh.remove(c, mh.profileService.RemoveBanner)
+}
+
+func (mh *MediaHandler) upload(c *fiber.Ctx, set func(context.Context, entity.User, []byte) (entity.User, error)) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		mh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "An image is required in the file field")
+	}
+	if header.Size > maxImageUpload {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "Image is too large")
+	}
+	file, err := header.Open()
+	if err != nil {
+		mh.logger.Error("Failed to open upload", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read image")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImageUpload))
+	if err != nil {
+		mh.logger.Error("Failed to read upload", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read image")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), imageTimeout)
+	defer cancel()
+
+	user, err := mh.userService.GetById(ctx, userId)
+	if err != nil {
+		mh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	user, err = set(ctx, user, data)
+	switch {
+	case errors.Is(err, service.ErrInvalidImage):
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, service.ErrImageTooLarge):
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, err.Error())
+	case err != nil:
+		mh.logger.Error("Failed to store image", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to store image")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewSelf(user)))
+}
+
+func (mh *MediaHandler) remove(c *fiber.Ctx, remove func(context.Context, entity.User) (entity.User, error)) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		mh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), imageTimeout)
+	defer cancel()
+
+	user, err := mh.userService.GetById(ctx, userId)
+	if err != nil {
+		mh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	user, err = remove(ctx, user)
+	if err != nil {
+		mh.logger.Error("Failed to remove image", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to remove image")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewSelf(user)))
+}
+
+// Get serves profile images. Every upload gets a new id, so a URL always
+// points to the same bytes and can be cached for good.
+func (mh *MediaHandler) Get(c *fiber.Ctx) error {
+	img, err := mh.profileService.OpenImage(c.Context(), c.Params("kind"), c.Params("userId"), c.Params("imageId"), c.Params("variant"))
+	if errors.Is(err, service.ErrImageNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		mh.logger.Error("Failed to open image", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load image")
+	}
+
+	c.Set(fiber.HeaderContentType, "image/webp")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	return c.Status(fiber.StatusOK).SendStream(img)
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type OAuthHandler struct {
+	logger           *zap.Logger
+	oauthService     *service.OAuthService
+	userService      *service.UserService
+	sessionService   *service.SessionService
+	twoFactorService *service.TwoFactorService
+}
+
+func NewOAuthHandler(
+	oauthService *service.OAuthService,
+	userService *service.UserService,
+	sessionService *service.SessionService,
+	twoFactorService *service.TwoFactorService,
+	logger *zap.Logger,
+) *OAuthHandler {
+	return &OAuthHandler{
+		logger:           logger,
+		oauthService:     oauthService,
+		userService:      userService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+	}
+}
+
+func (oh *OAuthHandler) Providers(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(oh.oauthService.Providers()))
+}
+
+type OAuthStartRequest struct {
+	DeviceName string `query:"device_name" validate:"max=255"`
+}
+
+func (oh *OAuthHandler) Start(c *fiber.Ctx) error {
+	req := &OAuthStartRequest{}
+
+	err := utils.ParseQuery(c, oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	url, nonce, err := oh.oauthService.Start(c.Context(), c.Params("provider"), "", req.DeviceName)
+	if err != nil {
+		return oh.oauthError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"url":   url,
+		"nonce": nonce,
+	}))
+}
+
+func (oh *OAuthHandler) Link(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		oh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	url, nonce, err := oh.oauthService.Start(c.Context(), c.Params("provider"), userId, "")
+	if err != nil {
+		return oh.oauthError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"url":   url,
+		"nonce": nonce,
+	}))
+}
+
+// OAuthCallbackRequest carries the code and state the provider redirected
+// with, and the nonce returned when the flow was started.
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=255"`
+	Nonce string `json:"nonce" validate:"required,max=255"`
+}
+
+func (oh *OAuthHandler) Callback(c *fiber.Ctx) error {
+	req := &OAuthCallbackRequest{}
+
+	err := utils.ParseBody(c, oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	result, err := oh.oauthService.Callback(c.Context(), c.Params("provider"), "", req.Code, req.State, req.Nonce)
+	if err != nil {
+		return oh.oauthError(err)
+	}
+
+	// A provider login replaces the password, not the second factor.
+	if result.User.TOTPEnabledAt != nil {
+		return twoFactorChallenge(c, oh.logger, oh.twoFactorService, result.User, result.DeviceName)
+	}
+
+	return startSession(c, oh.logger, oh.sessionService, result.User, result.DeviceName)
+}
+
+// LinkCallback completes a flow started by Link. It runs for the signed in
+// user, so a link flow started by someone else is rejected.
+func (oh *OAuthHandler) LinkCallback(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		oh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &OAuthCallbackRequest{}
+
+	err := utils.ParseBody(c, oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	if _, err = oh.oauthService.Callback(c.Context(), c.Params("provider"), userId, req.Code, req.State, req.Nonce); err != nil {
+		return oh.oauthError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"linked": true,
+	}))
+}
+
+func (oh *OAuthHandler) ListIdentities(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		oh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	identities, err := oh.oauthService.ListIdentities(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewIdentities(identities)))
+}
+
+type UnlinkIdentityRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+func (oh *OAuthHandler) Unlink(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		oh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &UnlinkIdentityRequest{}
+
+	err := utils.ParseBody(c, oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(oh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := oh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		oh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	if err = oh.oauthService.Unlink(c.Context(), user, req.Id); err != nil {
+		return oh.oauthError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func (oh *OAuthHandler) oauthError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrUnknownOAuthProvider):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidOAuthState), errors.Is(err, service.ErrOAuthEmailNotVerified):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrOAuthExchange):
+		oh.logger.Warn("OAuth code exchange failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusUnauthorized, service.ErrOAuthExchange.Error())
+	case errors.Is(err, service.ErrOAuthEmailTaken), errors.Is(err, service.ErrIdentityLinked), errors.Is(err, service.ErrLastLoginMethod):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		oh.logger.Error("OAuth operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "OAuth operation failed")
+	}
+}
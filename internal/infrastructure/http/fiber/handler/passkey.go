@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type PasskeyHandler struct {
+	logger         *zap.Logger
+	userService    *service.UserService
+	passkeyService *service.PasskeyService
+	sessionService *service.SessionService
+}
+
+func NewPasskeyHandler(
+	userService *service.UserService,
+	passkeyService *service.PasskeyService,
+	sessionService *service.SessionService,
+	logger *zap.Logger,
+) *PasskeyHandler {
+	return &PasskeyHandler{
+		logger:         logger,
+		userService:    userService,
+		passkeyService: passkeyService,
+		sessionService: sessionService,
+	}
+}
+
+func (ph *PasskeyHandler) BeginRegistration(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ph.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	user, err := ph.userService.GetById(c.Context(), userId)
+	if err != nil {
+		ph.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	options, err := ph.passkeyService.BeginRegistration(c.Context(), user)
+	if err != nil {
+		return ph.passkeyError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(options))
+}
+
+type FinishPasskeyRegistrationRequest struct {
+	Name       string          `json:"name" validate:"required,max=255"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (ph *PasskeyHandler) FinishRegistration(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ph.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &FinishPasskeyRegistrationRequest{}
+
+	err := utils.ParseBody(c, ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := ph.userService.GetById(c.Context(), userId)
+	if err != nil {
+		ph.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	passkey, err := ph.passkeyService.FinishRegistration(c.Context(), user, req.Name, req.Credential)
+	if err != nil {
+		return ph.passkeyError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewPasskey(passkey)))
+}
+
+func (ph *PasskeyHandler) List(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ph.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	passkeys, err := ph.passkeyService.List(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewPasskeys(passkeys)))
+}
+
+type DeletePasskeyRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+func (ph *PasskeyHandler) Delete(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ph.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &DeletePasskeyRequest{}
+
+	err := utils.ParseBody(c, ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = ph.passkeyService.Delete(c.Context(), userId, req.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func (ph *PasskeyHandler) BeginLogin(c *fiber.Ctx) error {
+	options, err := ph.passkeyService.BeginLogin(c.Context())
+	if err != nil {
+		return ph.passkeyError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(options))
+}
+
+type FinishPasskeyLoginRequest struct {
+	Credential json.RawMessage `json:"credential" validate:"required"`
+	DeviceName string          `json:"device_name" validate:"max=255"`
+}
+
+func (ph *PasskeyHandler) FinishLogin(c *fiber.Ctx) error {
+	req := &FinishPasskeyLoginRequest{}
+
+	err := utils.ParseBody(c, ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := ph.passkeyService.FinishLogin(c.Context(), req.Credential)
+	if err != nil {
+		return ph.passkeyError(err)
+	}
+
+	// Passkeys require user verification (PIN or biometrics) on the
+	// authenticator, so they already are two factors and skip the TOTP step.
+	return startSession(c, ph.logger, ph.sessionService, user, req.DeviceName)
+}
+
+func (ph *PasskeyHandler) passkeyError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrPasskeyChallenge):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrInvalidPasskey):
+		ph.logger.Warn("Passkey verification failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusUnauthorized, service.ErrInvalidPasskey.Error())
+	case errors.Is(err, service.ErrPasskeyExists), errors.Is(err, service.ErrTooManyPasskeys):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		ph.logger.Error("Passkey operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Passkey operation failed")
+	}
+}
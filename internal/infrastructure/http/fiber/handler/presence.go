@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type PresenceHandler struct {
+	logger          *zap.Logger
+	presenceService *service.PresenceService
+}
+
+func NewPresenceHandler(presenceService *service.PresenceService, logger *zap.Logger) *PresenceHandler {
+	return &PresenceHandler{
+		logger:          logger,
+		presenceService: presenceService,
+	}
+}
+
+// Get returns the presence of the user as they see it, so invisible shows
+// as invisible rather than offline.
+func (ph *PresenceHandler) Get(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ph.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	presence, err := ph.presenceService.Get(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewPresence(presence)))
+}
+
+// UpdatePresenceRequest changes the fields that are set; an empty
+// custom_status clears it.
+type UpdatePresenceRequest struct {
+	Status                *string    `json:"status" validate:"omitempty,oneof=online idle dnd invisible"`
+	CustomStatus          *string    `json:"custom_status" validate:"omitempty,max=128"`
+	CustomStatusExpiresAt *time.Time `json:"custom_status_expires_at"`
+}
+
+func (ph *PresenceHandler) Update(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		ph.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &UpdatePresenceRequest{}
+
+	err := utils.ParseBody(c, ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(ph.logger, req)
+	if err != nil {
+		return err
+	}
+
+	if req.Status != nil {
+		if _, err = ph.presenceService.SetStatus(c.Context(), userId, entity.PresenceStatus(*req.Status)); err != nil {
+			return ph.presenceError(err)
+		}
+	}
+	if req.CustomStatus != nil {
+		if _, err = ph.presenceService.SetCustomStatus(c.Context(), userId, *req.CustomStatus, req.CustomStatusExpiresAt); err != nil {
+			return ph.presenceError(err)
+		}
+	}
+
+	presence, err := ph.presenceService.Get(c.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewPresence(presence)))
+}
+
+func (ph *PresenceHandler) presenceError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidPresenceStatus),
+		errors.Is(err, service.ErrCustomStatusTooLong),
+		errors.Is(err, service.ErrCustomStatusExpired):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	default:
+		ph.logger.Error("Failed to update presence", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update presence")
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/jwt"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type SessionHandler struct {
+	logger         *zap.Logger
+	sessionService *service.SessionService
+}
+
+func NewSessionHandler(sessionService *service.SessionService, logger *zap.Logger) *SessionHandler {
+	return &SessionHandler{
+		logger:         logger,
+		sessionService: sessionService,
+	}
+}
+
+func (sh *SessionHandler) ListSessions(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		sh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	var currentId string
+	if token, ok := c.Locals("token").(jwt.Token); ok {
+		currentId, _ = token.Body["sid"].(string)
+	}
+
+	sessions, err := sh.sessionService.List(c.Context(), userId, currentId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewSessions(sessions)))
+}
+
+type RevokeSessionRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+func (sh *SessionHandler) Revoke(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		sh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &RevokeSessionRequest{}
+
+	err := utils.ParseBody(c, sh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(sh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = sh.sessionService.Revoke(c.Context(), userId, req.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+// startSession opens a session for an authenticated user and responds with
+// its token pair. It is shared by every login method.
+func startSession(c *fiber.Ctx, logger *zap.Logger, sessionService *service.SessionService, user entity.User, deviceName string) error {
+	tokens, err := sessionService.Start(c.Context(), user, entity.Session{
+		DeviceName: deviceName,
+		UserAgent:  userAgent(c),
+		Ip:         c.IP(),
+	})
+	if err != nil {
+		logger.Error("Failed to create session", zap.Error(err))
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Failed to create session")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(tokens))
+}
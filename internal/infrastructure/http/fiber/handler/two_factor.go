@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/validator"
+	"go.uber.org/zap"
+)
+
+type TwoFactorHandler struct {
+	logger           *zap.Logger
+	userService      *service.UserService
+	passwordService  *service.PasswordService
+	twoFactorService *service.TwoFactorService
+}
+
+func NewTwoFactorHandler(
+	userService *service.UserService,
+	passwordService *service.PasswordService,
+	twoFactorService *service.TwoFactorService,
+	logger *zap.Logger,
+) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		logger:           logger,
+		userService:      userService,
+		passwordService:  passwordService,
+		twoFactorService: twoFactorService,
+	}
+}
+
+func (tfh *TwoFactorHandler) Enroll(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		tfh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	user, err := tfh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		tfh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	secret, uri, err := tfh.twoFactorService.Enroll(c.Context(), user)
+	if err != nil {
+		return tfh.twoFactorError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	}))
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+func (tfh *TwoFactorHandler) Confirm(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		tfh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &TwoFactorCodeRequest{}
+
+	err := utils.ParseBody(c, tfh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(tfh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := tfh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		tfh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	codes, err := tfh.twoFactorService.Confirm(c.Context(), user, req.Code)
+	if err != nil {
+		return tfh.twoFactorError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"recovery_codes": codes,
+	}))
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required,max=255"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+func (tfh *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		tfh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &DisableTwoFactorRequest{}
+
+	err := utils.ParseBody(c, tfh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(tfh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := tfh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		tfh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	if err = tfh.passwordService.CheckPassword(req.Password, user.Password); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Invalid password")
+	}
+
+	if err = tfh.twoFactorService.Disable(c.Context(), user, req.Code); err != nil {
+		return tfh.twoFactorError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+func (tfh *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		tfh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &TwoFactorCodeRequest{}
+
+	err := utils.ParseBody(c, tfh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(tfh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := tfh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		tfh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	codes, err := tfh.twoFactorService.RegenerateRecoveryCodes(c.Context(), user, req.Code)
+	if err != nil {
+		return tfh.twoFactorError(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"recovery_codes": codes,
+	}))
+}
+
+func (tfh *TwoFactorHandler) twoFactorError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorEnabled):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrTwoFactorNotEnrolled):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	default:
+		tfh.logger.Error("Two-factor operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Two-factor operation failed")
+	}
+}
+
+// twoFactorChallenge answers a successful first factor of a user with two-factor
+// authentication enabled: instead of tokens the client gets a challenge to
+// complete through /auth/login/2fa.
+func twoFactorChallenge(c *fiber.Ctx, logger *zap.Logger, twoFactorService *service.TwoFactorService, user entity.User, deviceName string) error {
+	challengeToken, err := twoFactorService.CreateChallenge(c.Context(), user, deviceName)
+	if err != nil {
+		logger.Error("Failed to create two-factor challenge", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to login")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"two_factor_required": true,
+		"challenge_token":     challengeToken,
+	}))
+}
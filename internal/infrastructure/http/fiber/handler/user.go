@@ -1,21 +1,44 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/response"
 	"github.com/neokofg/callap-backend/internal/infrastructure/http/fiber/utils"
+	"github.com/neokofg/callap-backend/pkg/jwt"
+	"github.com/neokofg/callap-backend/pkg/validator"
 	"go.uber.org/zap"
 )
 
 type UserHandler struct {
-	logger      *zap.Logger
-	userService *service.UserService
+	logger                 *zap.Logger
+	userService            *service.UserService
+	passwordService        *service.PasswordService
+	sessionService         *service.SessionService
+	profileService         *service.ProfileService
+	accountDeletionService *service.AccountDeletionService
+	websocketService       *service.WebsocketService
 }
 
-func NewUserHandler(userService *service.UserService, logger *zap.Logger) *UserHandler {
+func NewUserHandler(
+	userService *service.UserService,
+	passwordService *service.PasswordService,
+	sessionService *service.SessionService,
+	profileService *service.ProfileService,
+	accountDeletionService *service.AccountDeletionService,
+	websocketService *service.WebsocketService,
+	logger *zap.Logger,
+) *UserHandler {
 	return &UserHandler{
-		logger:      logger,
-		userService: userService,
+		logger:                 logger,
+		userService:            userService,
+		passwordService:        passwordService,
+		sessionService:         sessionService,
+		profileService:         profileService,
+		accountDeletionService: accountDeletionService,
+		websocketService:       websocketService,
 	}
 }
 
@@ -32,5 +55,211 @@ func (uh *UserHandler) Me(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "User not found")
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(user))
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewSelf(user)))
+}
+
+type UpdateProfileRequest struct {
+	Name            *string `json:"name" validate:"omitempty,min=3,max=32"`
+	Tag             *string `json:"tag" validate:"omitempty,min=3,max=3"`
+	Email           *string `json:"email" validate:"omitempty,email,max=255"`
+	Discoverable    *bool   `json:"discoverable"`
+	CurrentPassword string  `json:"current_password" validate:"max=255"`
+}
+
+func (uh *UserHandler) Update(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		uh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &UpdateProfileRequest{}
+
+	err := utils.ParseBody(c, uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := uh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		uh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	// The email is where password resets and login links go, so moving it
+	// needs the password of accounts that have one.
+	if req.Email != nil && *req.Email != user.Email && user.Password != "" {
+		if err = uh.passwordService.CheckPassword(req.CurrentPassword, user.Password); err != nil {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "Invalid current password")
+		}
+	}
+
+	user, err = uh.profileService.Update(c.Context(), user, service.ProfileUpdate{
+		Name:         req.Name,
+		Tag:          req.Tag,
+		Email:        req.Email,
+		Discoverable: req.Discoverable,
+	})
+	switch {
+	case errors.Is(err, service.ErrProfileNameCooldown):
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrNameTagTaken), errors.Is(err, service.ErrEmailTaken):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		uh.logger.Error("Failed to update profile", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update profile")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewSelf(user)))
+}
+
+type SearchUsersRequest struct {
+	Query  string `query:"q" validate:"required,max=64"`
+	Cursor string `query:"cursor" validate:"max=255"`
+	Limit  int    `query:"limit" validate:"omitempty,gte=1,lte=50"`
+}
+
+// Search finds users by name; see service.UserService.Search for the
+// ranking.
+func (uh *UserHandler) Search(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		uh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &SearchUsersRequest{}
+
+	err := utils.ParseQuery(c, uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(uh.logger, req)
+	if err != nil {
+		return err
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+
+	results, next, err := uh.userService.Search(c.Context(), userId, req.Query, req.Cursor, req.Limit)
+	switch {
+	case errors.Is(err, service.ErrSearchQueryTooShort), errors.Is(err, service.ErrInvalidSearchCursor):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	case err != nil:
+		uh.logger.Error("Failed to search users", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to search users")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(response.NewUserSearchPage(results, next)))
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=255"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=32"`
+}
+
+func (uh *UserHandler) ChangePassword(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		uh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &ChangePasswordRequest{}
+
+	err := utils.ParseBody(c, uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := uh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		uh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	if err = uh.passwordService.CheckPassword(req.CurrentPassword, user.Password); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Invalid current password")
+	}
+
+	user.Password, err = uh.passwordService.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if _, err = uh.userService.Update(c.Context(), user); err != nil {
+		uh.logger.Error("Failed to update user", zap.Error(err))
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Failed to update user")
+	}
+
+	var currentId string
+	if token, ok := c.Locals("token").(jwt.Token); ok {
+		currentId, _ = token.Body["sid"].(string)
+	}
+	if err = uh.sessionService.RevokeOthers(c.Context(), userId, currentId); err != nil {
+		uh.logger.Error("Failed to revoke sessions", zap.Error(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponse())
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"max=255"`
+}
+
+// Delete schedules the deletion of the account. Accounts with a password
+// have to confirm it; the others only have their session to prove ownership.
+func (uh *UserHandler) Delete(c *fiber.Ctx) error {
+	userId, exists := c.Locals("userId").(string)
+	if !exists {
+		uh.logger.Warn("User ID required")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+	}
+
+	req := &DeleteAccountRequest{}
+
+	err := utils.ParseBody(c, uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	err = validator.Validate(uh.logger, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := uh.userService.GetById(c.Context(), userId)
+	if err != nil {
+		uh.logger.Warn("User not found", zap.String("userId", userId), zap.Error(err))
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	if user.Password != "" {
+		if err = uh.passwordService.CheckPassword(req.Password, user.Password); err != nil {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "Invalid password")
+		}
+	}
+
+	purgeAt, err := uh.accountDeletionService.Schedule(c.Context(), user)
+	if err != nil {
+		uh.logger.Error("Failed to schedule account deletion", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete account")
+	}
+
+	uh.websocketService.Disconnect(userId)
+
+	return c.Status(fiber.StatusOK).JSON(utils.MakeSuccessResponseWithData(fiber.Map{
+		"purge_at": purgeAt,
+	}))
 }
@@ -1,24 +1,28 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
 	"go.uber.org/zap"
 )
 
 type WebsocketHandler struct {
 	logger           *zap.Logger
 	websocketService *service.WebsocketService
+	presenceService  *service.PresenceService
 }
 
-func NewWebsocketHandler(websocketService *service.WebsocketService, logger *zap.Logger) *WebsocketHandler {
+func NewWebsocketHandler(websocketService *service.WebsocketService, presenceService *service.PresenceService, logger *zap.Logger) *WebsocketHandler {
 	return &WebsocketHandler{
 		logger:           logger,
 		websocketService: websocketService,
+		presenceService:  presenceService,
 	}
 }
 
@@ -35,11 +39,12 @@ func (wh *WebsocketHandler) Connect() fiber.Handler {
 		defer c.Conn.Close()
 
 		wh.websocketService.Join(userId, c)
-		defer wh.websocketService.Leave(userId)
+		defer wh.leave(userId, c)
+		wh.heartbeat(userId)
 
 		closeCh := make(chan struct{})
 		go func() {
-			pingTicker := time.NewTicker(30 * time.Second)
+			pingTicker := time.NewTicker(service.PresenceHeartbeatInterval)
 			defer pingTicker.Stop()
 			for {
 				select {
@@ -54,6 +59,7 @@ func (wh *WebsocketHandler) Connect() fiber.Handler {
 						return
 					}
 					wh.logger.Debug("ping sent", zap.String("userId", userId))
+					wh.heartbeat(userId)
 				case <-closeCh:
 					wh.logger.Debug("Ping goroutine stopped", zap.String("userId", userId))
 					return
@@ -87,6 +93,12 @@ func (wh *WebsocketHandler) Connect() fiber.Handler {
 
 			if action, ok := msg["action"].(string); ok {
 				switch action {
+				case "presence.set":
+					status, _ := msg["status"].(string)
+					_, err = wh.presenceService.SetStatus(context.Background(), userId, entity.PresenceStatus(status))
+					if err != nil {
+						wh.logger.Warn("Failed to set presence", zap.Error(err), zap.String("userId", userId))
+					}
 				default:
 					wh.logger.Debug("Unhandled action", zap.String("action", action), zap.String("userId", userId))
 				}
@@ -96,3 +108,20 @@ func (wh *WebsocketHandler) Connect() fiber.Handler {
 		EnableCompression: false,
 	})
 }
+
+func (wh *WebsocketHandler) heartbeat(userId string) {
+	if err := wh.presenceService.Heartbeat(context.Background(), userId); err != nil {
+		wh.logger.Warn("Failed to refresh presence", zap.Error(err), zap.String("userId", userId))
+	}
+}
+
+// leave drops the connection and takes the user offline, unless they already
+// reconnected on another socket.
+func (wh *WebsocketHandler) leave(userId string, c *websocket.Conn) {
+	if !wh.websocketService.Leave(userId, c) {
+		return
+	}
+	if err := wh.presenceService.Disconnect(context.Background(), userId); err != nil {
+		wh.logger.Warn("Failed to clear presence", zap.Error(err), zap.String("userId", userId))
+	}
+}
@@ -1,13 +1,15 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
 	"github.com/neokofg/callap-backend/pkg/jwt"
 )
 
-func AuthMiddleware(jwtService *jwt.Service) fiber.Handler {
+func AuthMiddleware(jwtService *jwt.Service, apiTokenService *service.APITokenService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var accessToken string
 
@@ -15,12 +17,33 @@ func AuthMiddleware(jwtService *jwt.Service) fiber.Handler {
 		if authHeader != nil && strings.HasPrefix(string(authHeader), "Bearer ") {
 			accessToken = strings.TrimPrefix(string(authHeader), "Bearer ")
 		}
-		if accessToken != "" {
+		if strings.HasPrefix(accessToken, service.APITokenPrefix) {
+			apiToken, err := apiTokenService.Authenticate(c.Context(), accessToken)
+			if errors.Is(err, service.ErrInvalidAPIToken) {
+				return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+			}
+			if err != nil {
+				return err
+			}
+			c.Locals("userId", apiToken.UserId.String())
+			c.Locals("scopes", apiToken.Scopes)
+		} else if accessToken != "" {
 			body, err := jwtService.ValidateToken(accessToken)
 			if err != nil {
 				return err
 			}
+			if tokenType, ok := body.Body["type"].(string); ok && tokenType == "refresh" {
+				return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+			}
+			revoked, err := jwtService.IsRevoked(c.Context(), body)
+			if err != nil {
+				return err
+			}
+			if revoked {
+				return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+			}
 			c.Locals("userId", body.UserId.String())
+			c.Locals("token", body)
 		}
 
 		return c.Next()
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScopeMiddleware restricts requests made with an API token: reads need the
+// read scope and every other method the write scope. An empty scope denies
+// that kind of request to API tokens. Session JWTs are not restricted.
+func ScopeMiddleware(readScope string, writeScope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals("scopes").([]string)
+		if !ok {
+			return c.Next()
+		}
+
+		scope := writeScope
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			scope = readScope
+		}
+		if scope == "" {
+			return fiber.NewError(fiber.StatusForbidden, "Not available for API tokens")
+		}
+		if !slices.Contains(scopes, scope) {
+			return fiber.NewError(fiber.StatusForbidden, "Missing scope "+scope)
+		}
+
+		return c.Next()
+	}
+}
+
+// SessionOnlyMiddleware rejects API tokens on account management routes, so
+// a leaked token can never be used to take over the account.
+func SessionOnlyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("scopes").([]string); ok {
+			return fiber.NewError(fiber.StatusForbidden, "Not available for API tokens")
+		}
+
+		return c.Next()
+	}
+}
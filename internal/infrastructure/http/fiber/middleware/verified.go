@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/neokofg/callap-backend/internal/application/service"
+)
+
+func VerifiedEmailMiddleware(userService *service.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId, exists := c.Locals("userId").(string)
+		if !exists {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+		}
+
+		user, err := userService.GetById(c.Context(), userId)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid access token")
+		}
+		if user.EmailVerifiedAt == nil {
+			return fiber.NewError(fiber.StatusForbidden, "Email is not verified")
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// APIToken describes an API token; only its prefix is shown, the token
+// itself is returned once at creation.
+type APIToken struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func NewAPIToken(token entity.APIToken) APIToken {
+	return APIToken{
+		Id:         token.Id.String(),
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.Scopes,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+func NewAPITokens(tokens []entity.APIToken) []APIToken {
+	views := make([]APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		views = append(views, NewAPIToken(token))
+	}
+	return views
+}
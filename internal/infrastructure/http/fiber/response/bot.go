@@ -0,0 +1,35 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// Bot is the owner's view of a bot. The webhook secret is only ever shown
+// once, when it is generated.
+type Bot struct {
+	Id         string    `json:"id"`
+	Name       string    `json:"name"`
+	Tag        string    `json:"tag"`
+	WebhookURL *string   `json:"webhook_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewBot(bot entity.Bot) Bot {
+	return Bot{
+		Id:         bot.Id.String(),
+		Name:       bot.Name,
+		Tag:        bot.Tag,
+		WebhookURL: bot.WebhookURL,
+		CreatedAt:  bot.CreatedAt,
+	}
+}
+
+func NewBots(bots []entity.Bot) []Bot {
+	views := make([]Bot, 0, len(bots))
+	for _, bot := range bots {
+		views = append(views, NewBot(bot))
+	}
+	return views
+}
@@ -0,0 +1,86 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+type ConversationSummary struct {
+	Id                 string     `json:"id"`
+	OtherUserId        string     `json:"other_user_id"`
+	OtherUserName      string     `json:"other_user_name"`
+	OtherUserTag       string     `json:"other_user_tag"`
+	OtherUserAvatarURL *string    `json:"other_user_avatar_url"`
+	LastMessage        *string    `json:"last_message"`
+	LastMessageAt      *time.Time `json:"last_message_at"`
+	UnreadCount        int        `json:"unread_count"`
+}
+
+func NewConversationSummaries(conversations []entity.ConversationSummary) []ConversationSummary {
+	views := make([]ConversationSummary, 0, len(conversations))
+	for _, conversation := range conversations {
+		views = append(views, ConversationSummary{
+			Id:                 conversation.ID,
+			OtherUserId:        conversation.OtherUserID,
+			OtherUserName:      conversation.OtherUserName,
+			OtherUserTag:       conversation.OtherUserTag,
+			OtherUserAvatarURL: service.AvatarURL(conversation.OtherUserID, conversation.OtherUserAvatarId),
+			LastMessage:        conversation.LastMessage,
+			LastMessageAt:      conversation.LastMessageAt,
+			UnreadCount:        conversation.UnreadCount,
+		})
+	}
+	return views
+}
+
+type Conversation struct {
+	Id           string    `json:"id"`
+	Type         string    `json:"type"`
+	Participants []string  `json:"participants"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func NewConversation(conversation *entity.ConversationDetails) Conversation {
+	return Conversation{
+		Id:           conversation.ID,
+		Type:         conversation.Type,
+		Participants: conversation.Participants,
+		CreatedAt:    conversation.CreatedAt,
+		UpdatedAt:    conversation.UpdatedAt,
+	}
+}
+
+// Message is a chat message. SenderId is empty and SenderName is
+// "Deleted user" once the sender's account has been purged.
+type Message struct {
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id"`
+	SenderId       string    `json:"sender_id"`
+	SenderName     string    `json:"sender_name"`
+	Content        string    `json:"content"`
+	IsRead         bool      `json:"is_read"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func NewMessage(message entity.Message) Message {
+	return Message{
+		Id:             message.ID,
+		ConversationId: message.ConversationId,
+		SenderId:       message.SenderID,
+		SenderName:     message.SenderName,
+		Content:        message.Content,
+		IsRead:         message.IsRead,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+func NewMessages(messages []entity.Message) []Message {
+	views := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		views = append(views, NewMessage(message))
+	}
+	return views
+}
@@ -0,0 +1,98 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// Friend is the view of an accepted friend. Presence is only filled in by
+// the friend list.
+type Friend struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Tag       string    `json:"tag"`
+	AvatarURL *string   `json:"avatar_url"`
+	Presence  *Presence `json:"presence,omitempty"`
+}
+
+func NewFriend(friend entity.FriendUser) Friend {
+	return Friend{
+		Id:        friend.Id.String(),
+		Name:      friend.Name,
+		Tag:       friend.Tag,
+		AvatarURL: service.AvatarURL(friend.Id.String(), friend.AvatarId),
+	}
+}
+
+func NewFriends(friends []entity.FriendUser) []Friend {
+	views := make([]Friend, 0, len(friends))
+	for _, friend := range friends {
+		views = append(views, NewFriend(friend))
+	}
+	return views
+}
+
+// NewFriendsWithPresence is NewFriends with the presence of each friend, as
+// returned by service.PresenceService.List.
+func NewFriendsWithPresence(friends []entity.FriendUser, presences map[string]entity.Presence) []Friend {
+	views := NewFriends(friends)
+	for i := range views {
+		if presence, ok := presences[views[i].Id]; ok {
+			view := NewPresence(presence)
+			views[i].Presence = &view
+		}
+	}
+	return views
+}
+
+// PendingFriend is an incoming friend request; Id identifies the request,
+// SenderId the user who sent it.
+type PendingFriend struct {
+	Id        string  `json:"id"`
+	SenderId  string  `json:"sender_id"`
+	Name      string  `json:"name"`
+	Tag       string  `json:"tag"`
+	AvatarURL *string `json:"avatar_url"`
+}
+
+func NewPendingFriends(pending []*entity.PendingFriend) []PendingFriend {
+	views := make([]PendingFriend, 0, len(pending))
+	for _, request := range pending {
+		views = append(views, PendingFriend{
+			Id:        request.ID,
+			SenderId:  request.SenderID,
+			Name:      request.Name,
+			Tag:       request.Tag,
+			AvatarURL: service.AvatarURL(request.SenderID, request.AvatarId),
+		})
+	}
+	return views
+}
+
+// OutgoingFriend is a pending request sent by the user; Id identifies the
+// request, RecipientId the user it was sent to.
+type OutgoingFriend struct {
+	Id          string    `json:"id"`
+	RecipientId string    `json:"recipient_id"`
+	Name        string    `json:"name"`
+	Tag         string    `json:"tag"`
+	AvatarURL   *string   `json:"avatar_url"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+func NewOutgoingFriends(outgoing []entity.OutgoingFriend) []OutgoingFriend {
+	views := make([]OutgoingFriend, 0, len(outgoing))
+	for _, request := range outgoing {
+		views = append(views, OutgoingFriend{
+			Id:          request.ID,
+			RecipientId: request.RecipientID,
+			Name:        request.Name,
+			Tag:         request.Tag,
+			AvatarURL:   service.AvatarURL(request.RecipientID, request.AvatarId),
+			SentAt:      request.SentAt,
+		})
+	}
+	return views
+}
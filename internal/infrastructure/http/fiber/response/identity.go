@@ -0,0 +1,28 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// Identity is a linked external account. The provider subject stays private.
+type Identity struct {
+	Id        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewIdentities(identities []entity.Identity) []Identity {
+	views := make([]Identity, 0, len(identities))
+	for _, identity := range identities {
+		views = append(views, Identity{
+			Id:        identity.Id.String(),
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	return views
+}
@@ -0,0 +1,32 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// Passkey describes a registered passkey without its credential record.
+type Passkey struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func NewPasskey(passkey entity.Passkey) Passkey {
+	return Passkey{
+		Id:         passkey.Id.String(),
+		Name:       passkey.Name,
+		LastUsedAt: passkey.LastUsedAt,
+		CreatedAt:  passkey.CreatedAt,
+	}
+}
+
+func NewPasskeys(passkeys []entity.Passkey) []Passkey {
+	views := make([]Passkey, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		views = append(views, NewPasskey(passkey))
+	}
+	return views
+}
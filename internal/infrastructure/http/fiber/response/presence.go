@@ -0,0 +1,25 @@
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// Presence is the view of a presence; invisible users are already reported
+// as offline by service.PresenceService.List.
+type Presence struct {
+	Status                string     `json:"status"`
+	CustomStatus          *string    `json:"custom_status"`
+	CustomStatusExpiresAt *time.Time `json:"custom_status_expires_at"`
+	LastSeenAt            *time.Time `json:"last_seen_at"`
+}
+
+func NewPresence(presence entity.Presence) Presence {
+	return Presence{
+		Status:                string(presence.Status),
+		CustomStatus:          presence.CustomStatus,
+		CustomStatusExpiresAt: presence.CustomStatusExpiresAt,
+		LastSeenAt:            presence.LastSeenAt,
+	}
+}
@@ -0,0 +1,232 @@
+package response
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	secretPasswordHash = "$argon2id$v=19$secret-password-hash"
+	secretTOTP         = "SECRET-TOTP-SEED"
+	secretCredential   = "secret-credential-record"
+	secretTokenHash    = "secret-token-hash"
+	secretSubject      = "secret-provider-subject"
+	otherUserEmail     = "other.user@example.com"
+)
+
+var secrets = []string{
+	secretPasswordHash,
+	secretTOTP,
+	secretCredential,
+	secretTokenHash,
+	secretSubject,
+}
+
+func TestViewsOmitSecrets(t *testing.T) {
+	now := time.Now()
+	avatarId := ulid.Make().String()
+	other := entity.User{
+		Id:         ulid.Make(),
+		Name:       "other",
+		Tag:        "abc",
+		Email:      otherUserEmail,
+		Password:   secretPasswordHash,
+		TOTPSecret: secretTOTP,
+		AvatarId:   &avatarId,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	message := entity.Message{
+		ID:             ulid.Make().String(),
+		ConversationId: ulid.Make().String(),
+		SenderID:       other.Id.String(),
+		SenderName:     other.Name,
+		Content:        "hello",
+		CreatedAt:      now,
+	}
+
+	tests := []struct {
+		name string
+		view any
+		// forbidden lists values that must not appear on top of secrets.
+		forbidden []string
+	}{
+		{
+			name: "self",
+			view: NewSelf(other),
+		},
+		{
+			name:      "public user",
+			view:      NewPublicUser(other),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name:      "friends",
+			view:      NewFriends([]entity.FriendUser{{Id: other.Id, Name: other.Name, Tag: other.Tag, AvatarId: &avatarId}}),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name: "passkeys",
+			view: NewPasskeys([]entity.Passkey{{
+				Id:           ulid.Make(),
+				UserId:       other.Id,
+				Name:         "laptop",
+				CredentialId: []byte(secretCredential),
+				Credential:   []byte(`{"publicKey":"` + secretCredential + `"}`),
+				CreatedAt:    now,
+			}}),
+			forbidden: []string{other.Id.String()},
+		},
+		{
+			name: "api tokens",
+			view: NewAPITokens([]entity.APIToken{{
+				Id:        ulid.Make(),
+				UserId:    other.Id,
+				Name:      "ci",
+				Prefix:    "cl_abc",
+				TokenHash: secretTokenHash,
+				Scopes:    []string{"user:read"},
+				CreatedAt: now,
+			}}),
+			forbidden: []string{other.Id.String()},
+		},
+		{
+			name: "identities",
+			view: NewIdentities([]entity.Identity{{
+				Id:        ulid.Make(),
+				UserId:    other.Id,
+				Provider:  "github",
+				Subject:   secretSubject,
+				CreatedAt: now,
+			}}),
+			forbidden: []string{other.Id.String()},
+		},
+		{
+			name: "sessions",
+			view: NewSessions([]entity.ActiveSession{{
+				ID:         ulid.Make().String(),
+				DeviceName: "phone",
+				CreatedAt:  now,
+				LastSeenAt: now,
+			}}),
+		},
+		{
+			name: "bots",
+			view: NewBots([]entity.Bot{{Id: ulid.Make(), Name: "bot", Tag: "bot", CreatedAt: now}}),
+		},
+		{
+			name: "conversations",
+			view: NewConversationSummaries([]entity.ConversationSummary{{
+				ID:                ulid.Make().String(),
+				OtherUserID:       other.Id.String(),
+				OtherUserName:     other.Name,
+				OtherUserTag:      other.Tag,
+				OtherUserAvatarId: &avatarId,
+				LastMessage:       &message.Content,
+				LastMessageAt:     &now,
+				UnreadCount:       1,
+			}}),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name: "conversation",
+			view: NewConversation(&entity.ConversationDetails{
+				ID:           message.ConversationId,
+				Type:         "direct",
+				Participants: []string{other.Id.String()},
+				CreatedAt:    now,
+				UpdatedAt:    now,
+			}),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name:      "messages",
+			view:      NewMessages([]entity.Message{message}),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name: "pending friends",
+			view: NewPendingFriends([]*entity.PendingFriend{{
+				ID:       ulid.Make().String(),
+				SenderID: other.Id.String(),
+				Name:     other.Name,
+				Tag:      other.Tag,
+				AvatarId: &avatarId,
+			}}),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name: "outgoing friends",
+			view: NewOutgoingFriends([]entity.OutgoingFriend{{
+				ID:          ulid.Make().String(),
+				RecipientID: other.Id.String(),
+				Name:        other.Name,
+				Tag:         other.Tag,
+				AvatarId:    &avatarId,
+				SentAt:      now,
+			}}),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name: "search",
+			view: NewUserSearchPage([]entity.UserSearchResult{{
+				Id:            other.Id,
+				Name:          other.Name,
+				Tag:           other.Tag,
+				AvatarId:      &avatarId,
+				MutualFriends: 2,
+				Rank:          entity.UserSearchRank{Relation: 1, Match: 2, Score: 3},
+			}}, "cursor"),
+			forbidden: []string{otherUserEmail},
+		},
+		{
+			name: "presence",
+			view: NewFriendsWithPresence(
+				[]entity.FriendUser{{Id: other.Id, Name: other.Name, Tag: other.Tag, AvatarId: &avatarId}},
+				map[string]entity.Presence{other.Id.String(): {
+					UserId:       other.Id.String(),
+					Status:       entity.PresenceOnline,
+					CustomStatus: &message.Content,
+					LastSeenAt:   &now,
+				}},
+			),
+			forbidden: []string{otherUserEmail},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.view)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			for _, value := range append(secrets, tt.forbidden...) {
+				if strings.Contains(string(body), value) {
+					t.Errorf("response contains %q: %s", value, body)
+				}
+			}
+		})
+	}
+}
+
+func TestSelfReportsCredentialsWithoutValues(t *testing.T) {
+	enabledAt := time.Now()
+	self := NewSelf(entity.User{
+		Id:            ulid.Make(),
+		Password:      secretPasswordHash,
+		TOTPSecret:    secretTOTP,
+		TOTPEnabledAt: &enabledAt,
+	})
+
+	if !self.HasPassword {
+		t.Error("HasPassword = false, want true")
+	}
+	if !self.TwoFactor {
+		t.Error("TwoFactor = false, want true")
+	}
+}
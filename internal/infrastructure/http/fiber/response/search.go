@@ -0,0 +1,40 @@
+package response
+
+import (
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+type UserSearchResult struct {
+	Id            string  `json:"id"`
+	Name          string  `json:"name"`
+	Tag           string  `json:"tag"`
+	AvatarURL     *string `json:"avatar_url"`
+	IsFriend      bool    `json:"is_friend"`
+	MutualFriends int     `json:"mutual_friends"`
+}
+
+// UserSearchPage is one page of search results; NextCursor is nil on the
+// last page.
+type UserSearchPage struct {
+	Users      []UserSearchResult `json:"users"`
+	NextCursor *string            `json:"next_cursor"`
+}
+
+func NewUserSearchPage(results []entity.UserSearchResult, nextCursor string) UserSearchPage {
+	page := UserSearchPage{Users: make([]UserSearchResult, 0, len(results))}
+	for _, result := range results {
+		page.Users = append(page.Users, UserSearchResult{
+			Id:            result.Id.String(),
+			Name:          result.Name,
+			Tag:           result.Tag,
+			AvatarURL:     service.AvatarURL(result.Id.String(), result.AvatarId),
+			IsFriend:      result.IsFriend,
+			MutualFriends: result.MutualFriends,
+		})
+	}
+	if nextCursor != "" {
+		page.NextCursor = &nextCursor
+	}
+	return page
+}
@@ -0,0 +1,69 @@
+// Package response holds the views of domain entities that handlers send to
+// clients. Handlers never serialize entities directly, so adding a column to
+// an entity cannot leak it by accident.
+package response
+
+import (
+	"time"
+
+	"github.com/neokofg/callap-backend/internal/application/service"
+	"github.com/neokofg/callap-backend/internal/domain/entity"
+)
+
+// Self is the authenticated user's view of their own account.
+type Self struct {
+	Id              string     `json:"id"`
+	Name            string     `json:"name"`
+	Tag             string     `json:"tag"`
+	AvatarURL       *string    `json:"avatar_url"`
+	BannerURL       *string    `json:"banner_url"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail    *string    `json:"pending_email"`
+	HasPassword     bool       `json:"has_password"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	Discoverable    bool       `json:"discoverable"`
+	IsBot           bool       `json:"is_bot"`
+	NameChangedAt   *time.Time `json:"name_changed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func NewSelf(user entity.User) Self {
+	return Self{
+		Id:              user.Id.String(),
+		Name:            user.Name,
+		Tag:             user.Tag,
+		AvatarURL:       service.AvatarURL(user.Id.String(), user.AvatarId),
+		BannerURL:       service.BannerURL(user.Id.String(), user.BannerId),
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		PendingEmail:    user.PendingEmail,
+		HasPassword:     user.Password != "",
+		TwoFactor:       user.TOTPEnabledAt != nil,
+		Discoverable:    user.Discoverable,
+		IsBot:           user.IsBot,
+		NameChangedAt:   user.NameChangedAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+// PublicUser is what any user may see about another one.
+type PublicUser struct {
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	Tag       string  `json:"tag"`
+	AvatarURL *string `json:"avatar_url"`
+	IsBot     bool    `json:"is_bot"`
+}
+
+func NewPublicUser(user entity.User) PublicUser {
+	return PublicUser{
+		Id:        user.Id.String(),
+		Name:      user.Name,
+		Tag:       user.Tag,
+		AvatarURL: service.AvatarURL(user.Id.String(), user.AvatarId),
+		IsBot:     user.IsBot,
+	}
+}
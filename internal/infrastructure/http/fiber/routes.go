@@ -17,9 +17,12 @@ func InitRoutes(fiberApp *fiber.App, handlers *handler.Handlers, services *servi
 	}
 
 	v1 := fiberApp.Group("/api").Group("/v1")
-	routes.authRoutes(v1)
+	routes.authRoutes(v1, services)
 	routes.userRoutes(v1, services)
+	routes.exportRoutes(v1)
+	routes.mediaRoutes(v1)
 	routes.websocketRoute(fiberApp, services)
+	routes.wellKnownRoutes(fiberApp)
 
 	return fiberApp
 }
@@ -27,45 +30,167 @@ func InitRoutes(fiberApp *fiber.App, handlers *handler.Handlers, services *servi
 func (r *Routes) websocketRoute(fiberRouter fiber.Router, services *service.Services) {
 	groupWs := fiberRouter.Group("/ws",
 		middleware.WebsocketMiddleware(),
-		middleware.AuthMiddleware(services.JWT),
+		middleware.AuthMiddleware(services.JWT, services.APITokenService),
+		middleware.ScopeMiddleware(service.ScopeMessagesRead, ""),
 	)
 	groupWs.Get("/connect", r.handlers.WebsocketHandler.Connect())
 }
 
-func (r *Routes) authRoutes(fiberRouter fiber.Router) {
+func (r *Routes) wellKnownRoutes(fiberRouter fiber.Router) {
+	groupWellKnown := fiberRouter.Group("/.well-known")
+	groupWellKnown.Get("/jwks.json", r.handlers.AuthHandler.JWKS)
+}
+
+func (r *Routes) authRoutes(fiberRouter fiber.Router, services *service.Services) {
 	groupAuth := fiberRouter.Group("/auth")
 	groupAuth.Post("/register", r.handlers.AuthHandler.Register)
 	groupAuth.Post("/login", r.handlers.AuthHandler.Login)
+	groupAuth.Post("/login/2fa", r.handlers.AuthHandler.LoginTwoFactor)
+	groupAuth.Post("/refresh", r.handlers.AuthHandler.Refresh)
+	groupAuth.Post("/logout", middleware.AuthMiddleware(services.JWT, services.APITokenService), r.handlers.AuthHandler.Logout)
+	groupAuth.Post("/logout-all", middleware.AuthMiddleware(services.JWT, services.APITokenService), middleware.SessionOnlyMiddleware(), r.handlers.AuthHandler.LogoutAll)
+	groupAuth.Post("/verify-email", r.handlers.AuthHandler.VerifyEmail)
+	groupAuth.Post("/verify-email/resend", middleware.AuthMiddleware(services.JWT, services.APITokenService), middleware.SessionOnlyMiddleware(), r.handlers.AuthHandler.ResendVerification)
+	groupAuth.Post("/forgot-password", r.handlers.AuthHandler.ForgotPassword)
+	groupAuth.Post("/reset-password", r.handlers.AuthHandler.ResetPassword)
+	r.oauthRoutes(groupAuth)
+	r.magicLinkRoutes(groupAuth)
+	r.passkeyLoginRoutes(groupAuth)
+}
+
+func (r *Routes) passkeyLoginRoutes(fiberRouter fiber.Router) {
+	groupPasskey := fiberRouter.Group("/passkey")
+	groupPasskey.Post("/login/begin", r.handlers.PasskeyHandler.BeginLogin)
+	groupPasskey.Post("/login/finish", r.handlers.PasskeyHandler.FinishLogin)
+}
+
+func (r *Routes) magicLinkRoutes(fiberRouter fiber.Router) {
+	groupMagic := fiberRouter.Group("/magic")
+	groupMagic.Post("/send", r.handlers.MagicLinkHandler.Send)
+	groupMagic.Post("/verify", r.handlers.MagicLinkHandler.Verify)
+}
+
+func (r *Routes) oauthRoutes(fiberRouter fiber.Router) {
+	groupOAuth := fiberRouter.Group("/oauth")
+	groupOAuth.Get("/providers", r.handlers.OAuthHandler.Providers)
+	groupOAuth.Get("/:provider/start", r.handlers.OAuthHandler.Start)
+	groupOAuth.Post("/:provider/callback", r.handlers.OAuthHandler.Callback)
+}
+
+// exportRoutes serves finished data exports; the token in the link is the
+// only credential, see service.ExportDownloadPath.
+func (r *Routes) exportRoutes(fiberRouter fiber.Router) {
+	groupExport := fiberRouter.Group("/export")
+	groupExport.Get("/download", r.handlers.ExportHandler.Download)
+}
+
+// mediaRoutes serves profile images without authentication, like the
+// avatars shown next to messages; see service.MediaPath.
+func (r *Routes) mediaRoutes(fiberRouter fiber.Router) {
+	groupMedia := fiberRouter.Group("/media")
+	groupMedia.Get("/:kind/:userId/:imageId/:variant", r.handlers.MediaHandler.Get)
 }
 
 func (r *Routes) userRoutes(fiberRouter fiber.Router, services *service.Services) {
-	groupUser := fiberRouter.Group("/user", middleware.AuthMiddleware(services.JWT))
-	groupUser.Get("/me", r.handlers.UserHandler.Me)
+	groupUser := fiberRouter.Group("/user", middleware.AuthMiddleware(services.JWT, services.APITokenService))
+	groupUser.Get("/me", middleware.ScopeMiddleware(service.ScopeUserRead, ""), r.handlers.UserHandler.Me)
+	groupUser.Patch("/me", middleware.SessionOnlyMiddleware(), r.handlers.UserHandler.Update)
+	groupUser.Delete("/me", middleware.SessionOnlyMiddleware(), r.handlers.UserHandler.Delete)
+	groupUser.Post("/me/avatar", middleware.SessionOnlyMiddleware(), r.handlers.MediaHandler.UploadAvatar)
+	groupUser.Delete("/me/avatar", middleware.SessionOnlyMiddleware(), r.handlers.MediaHandler.DeleteAvatar)
+	groupUser.Post("/me/banner", middleware.SessionOnlyMiddleware(), r.handlers.MediaHandler.UploadBanner)
+	groupUser.Delete("/me/banner", middleware.SessionOnlyMiddleware(), r.handlers.MediaHandler.DeleteBanner)
+	groupUser.Get("/search", middleware.ScopeMiddleware(service.ScopeUserRead, ""), r.handlers.UserHandler.Search)
+	groupUser.Get("/presence", middleware.ScopeMiddleware(service.ScopeUserRead, ""), r.handlers.PresenceHandler.Get)
+	groupUser.Patch("/presence", middleware.SessionOnlyMiddleware(), r.handlers.PresenceHandler.Update)
+	groupUser.Post("/export", middleware.SessionOnlyMiddleware(), r.handlers.ExportHandler.Request)
+	groupUser.Post("/password", middleware.SessionOnlyMiddleware(), r.handlers.UserHandler.ChangePassword)
+	r.sessionRoutes(groupUser, services)
+	r.twoFactorRoutes(groupUser, services)
+	r.identityRoutes(groupUser, services)
+	r.passkeyRoutes(groupUser, services)
+	r.apiTokenRoutes(groupUser, services)
+	r.botRoutes(groupUser, services)
 	r.friendRoutes(groupUser, services)
 	r.conversationRoutes(groupUser, services)
 }
 
+func (r *Routes) sessionRoutes(fiberRouter fiber.Router, services *service.Services) {
+	groupSession := fiberRouter.Group("/session", middleware.SessionOnlyMiddleware())
+	groupSession.Get("/list", r.handlers.SessionHandler.ListSessions)
+	groupSession.Delete("/revoke", r.handlers.SessionHandler.Revoke)
+}
+
+func (r *Routes) twoFactorRoutes(fiberRouter fiber.Router, services *service.Services) {
+	groupTwoFactor := fiberRouter.Group("/2fa", middleware.SessionOnlyMiddleware())
+	groupTwoFactor.Post("/enroll", r.handlers.TwoFactorHandler.Enroll)
+	groupTwoFactor.Post("/confirm", r.handlers.TwoFactorHandler.Confirm)
+	groupTwoFactor.Post("/disable", r.handlers.TwoFactorHandler.Disable)
+	groupTwoFactor.Post("/recovery-codes", r.handlers.TwoFactorHandler.RegenerateRecoveryCodes)
+}
+
+func (r *Routes) identityRoutes(fiberRouter fiber.Router, services *service.Services) {
+	groupIdentity := fiberRouter.Group("/identity", middleware.SessionOnlyMiddleware())
+	groupIdentity.Get("/list", r.handlers.OAuthHandler.ListIdentities)
+	groupIdentity.Post("/:provider/link", r.handlers.OAuthHandler.Link)
+	groupIdentity.Post("/:provider/callback", r.handlers.OAuthHandler.LinkCallback)
+	groupIdentity.Delete("/unlink", r.handlers.OAuthHandler.Unlink)
+}
+
+func (r *Routes) passkeyRoutes(fiberRouter fiber.Router, services *service.Services) {
+	groupPasskey := fiberRouter.Group("/passkey", middleware.SessionOnlyMiddleware())
+	groupPasskey.Get("/list", r.handlers.PasskeyHandler.List)
+	groupPasskey.Post("/register/begin", r.handlers.PasskeyHandler.BeginRegistration)
+	groupPasskey.Post("/register/finish", r.handlers.PasskeyHandler.FinishRegistration)
+	groupPasskey.Delete("/delete", r.handlers.PasskeyHandler.Delete)
+}
+
+func (r *Routes) apiTokenRoutes(fiberRouter fiber.Router, services *service.Services) {
+	groupAPIToken := fiberRouter.Group("/tokens", middleware.SessionOnlyMiddleware())
+	groupAPIToken.Get("/scopes", r.handlers.APITokenHandler.Scopes)
+	groupAPIToken.Get("/list", r.handlers.APITokenHandler.List)
+	groupAPIToken.Post("/create", r.handlers.APITokenHandler.Create)
+	groupAPIToken.Delete("/revoke", r.handlers.APITokenHandler.Revoke)
+}
+
+func (r *Routes) botRoutes(fiberRouter fiber.Router, services *service.Services) {
+	groupBot := fiberRouter.Group("/bots", middleware.SessionOnlyMiddleware())
+	groupBot.Get("/list", r.handlers.BotHandler.List)
+	groupBot.Post("/create", middleware.VerifiedEmailMiddleware(services.UserService), r.handlers.BotHandler.Create)
+	groupBot.Post("/token", r.handlers.BotHandler.RegenerateToken)
+	groupBot.Post("/webhook", r.handlers.BotHandler.SetWebhook)
+	groupBot.Delete("/delete", r.handlers.BotHandler.Delete)
+}
+
 func (r *Routes) friendRoutes(fiberRouter fiber.Router, services *service.Services) {
-	groupFriend := fiberRouter.Group("/friend")
-	groupFriend.Post("/add", r.handlers.FriendHandler.AddFriend)
+	groupFriend := fiberRouter.Group("/friend", middleware.ScopeMiddleware(service.ScopeFriendsRead, service.ScopeFriendsWrite))
+	groupFriend.Post("/add", middleware.VerifiedEmailMiddleware(services.UserService), r.handlers.FriendHandler.AddFriend)
 	groupFriend.Get("/pending", r.handlers.FriendHandler.GetPending)
+	groupFriend.Get("/outgoing", r.handlers.FriendHandler.GetOutgoing)
+	groupFriend.Post("/cancel", r.handlers.FriendHandler.Cancel)
 	groupFriend.Post("/accept", r.handlers.FriendHandler.Accept)
 	groupFriend.Post("/decline", r.handlers.FriendHandler.Decline)
 	groupFriend.Delete("/delete", r.handlers.FriendHandler.Delete)
 	groupFriend.Get("/list", r.handlers.FriendHandler.ListFriends)
+	groupFriend.Get("/blocked", r.handlers.FriendHandler.ListBlocked)
+	groupFriend.Post("/block", r.handlers.FriendHandler.Block)
+	groupFriend.Post("/unblock", r.handlers.FriendHandler.Unblock)
 }
 
 func (r *Routes) conversationRoutes(fiberRouter fiber.Router, services *service.Services) {
+	// The scope check is attached per route rather than to the group: group
+	// middleware would also run for the nested message routes.
+	scope := middleware.ScopeMiddleware(service.ScopeConversationsRead, service.ScopeConversationsWrite)
 	groupConversation := fiberRouter.Group("/conversation")
-	groupConversation.Post("/getOrCreate", r.handlers.ConversationHandler.GetOrCreate)
-	groupConversation.Get("/list", r.handlers.ConversationHandler.ListConversations)
-	groupConversation.Get("/get", r.handlers.ConversationHandler.GetConversation)
-	groupConversation.Post("/hide", r.handlers.ConversationHandler.Hide)
+	groupConversation.Post("/getOrCreate", scope, r.handlers.ConversationHandler.GetOrCreate)
+	groupConversation.Get("/list", scope, r.handlers.ConversationHandler.ListConversations)
+	groupConversation.Get("/get", scope, r.handlers.ConversationHandler.GetConversation)
+	groupConversation.Post("/hide", scope, r.handlers.ConversationHandler.Hide)
 	r.messageRoutes(groupConversation, services)
 }
 
 func (r *Routes) messageRoutes(fiberRouter fiber.Router, services *service.Services) {
-	groupMessage := fiberRouter.Group("/message")
+	groupMessage := fiberRouter.Group("/message", middleware.ScopeMiddleware(service.ScopeMessagesRead, service.ScopeMessagesWrite))
 	groupMessage.Get("/list", r.handlers.ConversationHandler.ListMessages)
 	groupMessage.Post("/new", r.handlers.ConversationHandler.NewMessage)
 	groupMessage.Delete("/delete", r.handlers.ConversationHandler.DeleteMessage)
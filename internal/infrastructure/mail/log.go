@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// LogMailer is meant for local development: messages are written to the log
+// and, when a directory is configured, dumped there as plain text files.
+type LogMailer struct {
+	dir    string
+	from   string
+	logger *zap.Logger
+}
+
+func NewLogMailer(config Config, logger *zap.Logger) *LogMailer {
+	return &LogMailer{
+		dir:    config.Dir,
+		from:   config.From,
+		logger: logger,
+	}
+}
+
+func (lm *LogMailer) Send(c context.Context, msg Message) error {
+	lm.logger.Info("Mail sent",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+
+	if lm.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(lm.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail dir: %w", err)
+	}
+	content := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\nDate: %s\n\n%s\n",
+		lm.from, msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), msg.Body)
+	path := filepath.Join(lm.dir, ulid.Make().String()+".eml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	return nil
+}
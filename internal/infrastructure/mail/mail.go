@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type Driver string
+
+const (
+	DriverSMTP Driver = "smtp"
+	DriverLog  Driver = "log"
+)
+
+type Config struct {
+	Driver   Driver
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Dir      string
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(c context.Context, msg Message) error
+}
+
+func NewMailer(config Config, logger *zap.Logger) Mailer {
+	if config.Driver == DriverSMTP {
+		return NewSMTPMailer(config)
+	}
+	return NewLogMailer(config, logger)
+}
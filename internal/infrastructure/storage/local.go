@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps objects as files below a directory. Objects are written to
+// a temporary file first so readers never see a partial object.
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(config Config) (*LocalStore, error) {
+	if config.Dir == "" {
+		return nil, errors.New("storage dir is required for the local driver")
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage dir: %w", err)
+	}
+	return &LocalStore{dir: config.Dir}, nil
+}
+
+func (ls *LocalStore) Put(c context.Context, key string, r io.Reader) error {
+	name, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (ls *LocalStore) Open(c context.Context, key string) (io.ReadCloser, error) {
+	name, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (ls *LocalStore) Delete(c context.Context, key string) error {
+	name, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path maps a key to a file below the storage dir, refusing keys that would
+// escape it.
+func (ls *LocalStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return filepath.Join(ls.dir, filepath.FromSlash(clean)), nil
+}
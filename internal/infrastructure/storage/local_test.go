@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestLocalStore(t *testing.T) *LocalStore {
+	t.Helper()
+	store, err := NewLocalStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	return store
+}
+
+func TestLocalStorePathStaysInDir(t *testing.T) {
+	store := newTestLocalStore(t)
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "exports/user.zip", want: "exports/user.zip"},
+		{key: "../outside", want: "outside"},
+		{key: "../../etc/passwd", want: "etc/passwd"},
+		{key: "avatars/../../../secret", want: "secret"},
+		{key: "/absolute/key", want: "absolute/key"},
+		{key: "a/./b//c", want: "a/b/c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := store.path(tt.key)
+			if err != nil {
+				t.Fatalf("path(%q): %v", tt.key, err)
+			}
+			if want := filepath.Join(store.dir, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("path(%q) = %q, want %q", tt.key, got, want)
+			}
+		})
+	}
+}
+
+func TestLocalStorePathRejectsInvalidKeys(t *testing.T) {
+	store := newTestLocalStore(t)
+
+	for _, key := range []string{"", "/", "..", "../", `a\b`, `..\..\secret`} {
+		if _, err := store.path(key); err == nil {
+			t.Errorf("path(%q) succeeded, want an error", key)
+		}
+	}
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store := newTestLocalStore(t)
+	c := context.Background()
+
+	if err := store.Put(c, "avatars/u/i/64.webp", strings.NewReader("image")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := store.Open(c, "avatars/u/i/64.webp")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "image" {
+		t.Fatalf("Open read %q, %v; want %q", data, err, "image")
+	}
+
+	if err = store.Delete(c, "avatars/u/i/64.webp"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err = store.Delete(c, "avatars/u/i/64.webp"); err != nil {
+		t.Errorf("Delete of a missing object: %v, want nil", err)
+	}
+	if _, err = store.Open(c, "avatars/u/i/64.webp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete: %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the size of the parts objects are uploaded in. Objects are
+// streamed without a known size, for which the client would otherwise buffer
+// parts sized for the largest possible object.
+const s3PartSize = 16 << 20
+
+// S3Store keeps objects in a bucket of an S3 compatible service such as AWS
+// S3 or MinIO. The bucket has to exist already.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(config Config) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("storage endpoint and bucket are required for the s3 driver")
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{client: client, bucket: config.Bucket}, nil
+}
+
+func (ss *S3Store) Put(c context.Context, key string, r io.Reader) error {
+	_, err := ss.client.PutObject(c, ss.bucket, key, r, -1, minio.PutObjectOptions{PartSize: s3PartSize})
+	return err
+}
+
+func (ss *S3Store) Open(c context.Context, key string) (io.ReadCloser, error) {
+	object, err := ss.client.GetObject(c, ss.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat makes a missing key show up here rather than on
+	// the first read.
+	if _, err = object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (ss *S3Store) Delete(c context.Context, key string) error {
+	return ss.client.RemoveObject(c, ss.bucket, key, minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+const testBucket = "callap"
+
+// fakeS3 serves the parts of the S3 API S3Store uses: multipart uploads,
+// GET, HEAD and DELETE of objects in testBucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	// parts is the number of parts the last completed upload had.
+	parts   int
+	aborted int
+}
+
+func newTestS3Store(t *testing.T) (*S3Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return store, fake
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok || key == "" {
+		s3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	q := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		uploadId := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[uploadId] = map[int][]byte{}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: testBucket, Key: key, UploadId: uploadId})
+
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		partNumber, err := strconv.Atoi(q.Get("partNumber"))
+		if !ok || err != nil {
+			s3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		data, err := readPayload(r)
+		if err != nil {
+			s3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		parts[partNumber] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, partNumber))
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		if !ok || xml.NewDecoder(r.Body).Decode(&complete) != nil {
+			s3Error(w, r, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		var object []byte
+		for _, part := range complete.Parts {
+			object = append(object, parts[part.PartNumber]...)
+		}
+		f.objects[key] = object
+		f.parts = len(complete.Parts)
+		delete(f.uploads, q.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: testBucket, Key: key, ETag: `"object"`})
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		f.aborted++
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			s3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"object"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		if r.Method == http.MethodGet {
+			w.Write(object)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// readPayload returns the body of an upload, decoding the aws-chunked
+// encoding the client uses for signed streaming over plain HTTP.
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	body := bufio.NewReader(r.Body)
+	for {
+		header, err := body.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err = io.ReadFull(body, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func s3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string
+		Message  string
+		Resource string
+	}{Code: code, Message: code, Resource: r.URL.Path})
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	store, fake := newTestS3Store(t)
+	c := context.Background()
+
+	if err := store.Put(c, "avatars/u/i/64.webp", strings.NewReader("image")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := string(fake.objects["avatars/u/i/64.webp"]); got != "image" {
+		t.Fatalf("stored %q, want %q", got, "image")
+	}
+
+	r, err := store.Open(c, "avatars/u/i/64.webp")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "image" {
+		t.Fatalf("Open read %q, %v; want %q", data, err, "image")
+	}
+
+	if err = store.Delete(c, "avatars/u/i/64.webp"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err = store.Delete(c, "avatars/u/i/64.webp"); err != nil {
+		t.Errorf("Delete of a missing object: %v, want nil", err)
+	}
+	if _, err = store.Open(c, "avatars/u/i/64.webp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete: %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StorePutStreamsParts(t *testing.T) {
+	store, fake := newTestS3Store(t)
+
+	object := bytes.Repeat([]byte("0123456789abcdef"), s3PartSize/16+1)
+	if err := store.Put(context.Background(), "exports/u/e.zip", bytes.NewReader(object)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if !bytes.Equal(fake.objects["exports/u/e.zip"], object) {
+		t.Errorf("stored %d bytes, want the %d bytes put", len(fake.objects["exports/u/e.zip"]), len(object))
+	}
+	if fake.parts != 2 {
+		t.Errorf("uploaded in %d parts, want 2 of at most %d bytes", fake.parts, s3PartSize)
+	}
+}
+
+func TestS3StorePutAbortsOnReadError(t *testing.T) {
+	store, fake := newTestS3Store(t)
+
+	r := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("read failed")))
+	if err := store.Put(context.Background(), "exports/u/e.zip", r); err == nil {
+		t.Fatal("Put succeeded, want the read error")
+	}
+	if _, ok := fake.objects["exports/u/e.zip"]; ok {
+		t.Error("a partial object was stored")
+	}
+	if fake.aborted != 1 || len(fake.uploads) != 0 {
+		t.Errorf("aborted %d uploads with %d left open, want the upload aborted", fake.aborted, len(fake.uploads))
+	}
+}
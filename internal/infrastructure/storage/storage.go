@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+type Driver string
+
+const (
+	DriverLocal Driver = "local"
+	DriverS3    Driver = "s3"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Config struct {
+	Driver Driver
+	Dir    string
+
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// Store keeps binary objects under slash separated keys such as
+// "exports/<user id>/<export id>.zip".
+type Store interface {
+	Put(c context.Context, key string, r io.Reader) error
+	Open(c context.Context, key string) (io.ReadCloser, error)
+	Delete(c context.Context, key string) error
+}
+
+func NewStore(config Config) (Store, error) {
+	switch config.Driver {
+	case DriverLocal, "":
+		return NewLocalStore(config)
+	case DriverS3:
+		return NewS3Store(config)
+	default:
+		return nil, errors.New("unknown storage driver: " + string(config.Driver))
+	}
+}
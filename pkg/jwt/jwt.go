@@ -1,8 +1,11 @@
 package jwt
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,6 +13,7 @@ import (
 )
 
 type Token struct {
+	Id        string
 	UserId    ulid.ULID
 	ExpiresAt time.Time
 	IssuedAt  time.Time
@@ -18,24 +22,73 @@ type Token struct {
 
 type Config struct {
 	Secret          string
+	Algorithm       string
+	PrivateKeyPath  string
+	PublicKeyPaths  []string
 	AccessTokenTTL  int
 	RefreshTokenTTL int
 	Issuer          string
 }
 
+type RevocationStore interface {
+	RevokeToken(c context.Context, id string, ttl time.Duration) error
+	RevokeUser(c context.Context, userId string, before time.Time, ttl time.Duration) error
+	IsRevoked(c context.Context, userId string, issuedAt time.Time, ids ...string) (bool, error)
+}
+
 type Service struct {
-	config Config
+	config     Config
+	store      RevocationStore
+	signingKey *key
+	keys       map[string]*key
 }
 
-func NewService(config Config) *Service {
-	return &Service{
-		config: config,
+func NewService(config Config, store RevocationStore) (*Service, error) {
+	signingKey, err := loadSigningKey(config)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*key{
+		signingKey.id: signingKey,
+	}
+	// Tokens minted before asymmetric keys were introduced carry no kid and are
+	// HMAC-signed, so the secret stays usable for verification while they expire.
+	if _, ok := keys[hmacKeyID]; !ok && config.Secret != "" {
+		keys[hmacKeyID] = &key{
+			id:     hmacKeyID,
+			method: jwt.SigningMethodHS256,
+			verify: []byte(config.Secret),
+		}
+	}
+	for _, path := range config.PublicKeyPaths {
+		if path == "" {
+			continue
+		}
+		verificationKey, err := loadVerificationKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := keys[verificationKey.id]; !ok {
+			keys[verificationKey.id] = verificationKey
+		}
 	}
+
+	return &Service{
+		config:     config,
+		store:      store,
+		signingKey: signingKey,
+		keys:       keys,
+	}, nil
 }
 
 type jwtToken struct {
 	UserID ulid.ULID      `json:"user_id"`
 	Body   map[string]any `json:"body,omitempty"`
+	// IssuedAtMs is "iat" in milliseconds. "iat" only has whole seconds,
+	// which is too coarse to tell a token minted right before a user-wide
+	// revocation from one minted right after it.
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -48,9 +101,11 @@ func (s *Service) GenerateToken(userID ulid.ULID, body map[string]any) (string,
 
 	now := time.Now()
 	tokenClaims := jwtToken{
-		UserID: userID,
-		Body:   body,
+		UserID:     userID,
+		Body:       body,
+		IssuedAtMs: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       ulid.Make().String(),
 			Issuer:   s.config.Issuer,
 			IssuedAt: jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(
@@ -59,8 +114,9 @@ func (s *Service) GenerateToken(userID ulid.ULID, body map[string]any) (string,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims)
-	tokenString, err := token.SignedString([]byte(s.config.Secret))
+	token := jwt.NewWithClaims(s.signingKey.method, tokenClaims)
+	token.Header["kid"] = s.signingKey.id
+	tokenString, err := token.SignedString(s.signingKey.sign)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -73,10 +129,18 @@ func (s *Service) ValidateToken(tokenString string) (Token, error) {
 		tokenString,
 		&jwtToken{},
 		func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			kid, ok := token.Header["kid"].(string)
+			if !ok {
+				kid = hmacKeyID
+			}
+			k, ok := s.keys[kid]
+			if !ok {
+				return nil, fmt.Errorf("unknown key id: %s", kid)
+			}
+			if token.Method.Alg() != k.method.Alg() {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(s.config.Secret), nil
+			return k.verify, nil
 		},
 	)
 	if err != nil {
@@ -92,10 +156,16 @@ func (s *Service) ValidateToken(tokenString string) (Token, error) {
 		return Token{}, errors.New("invalid token claims")
 	}
 
+	issuedAt := claims.IssuedAt.Time
+	if claims.IssuedAtMs != 0 {
+		issuedAt = time.UnixMilli(claims.IssuedAtMs)
+	}
+
 	return Token{
+		Id:        claims.ID,
 		UserId:    claims.UserID,
 		ExpiresAt: claims.ExpiresAt.Time,
-		IssuedAt:  claims.IssuedAt.Time,
+		IssuedAt:  issuedAt,
 		Body:      claims.Body,
 	}, nil
 }
@@ -114,10 +184,57 @@ func (s *Service) RefreshToken(tokenString string) (string, error) {
 	return "", errors.New("token is too old to refresh")
 }
 
+// JWKS returns the public verification keys so other services can check
+// tokens without holding the signing secret. HMAC keys are never published.
+// Keys are sorted by kid so the response stays stable between requests.
+func (s *Service) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		if jwk, ok := k.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	slices.SortFunc(set.Keys, func(a JWK, b JWK) int {
+		return strings.Compare(a.Kid, b.Kid)
+	})
+	return set
+}
+
 func (s *Service) GetTTL() (int, int) {
 	return s.config.AccessTokenTTL, s.config.RefreshTokenTTL
 }
 
-func (s *Service) InvalidateToken(token string) error {
-	return nil
+func (s *Service) IsRevoked(c context.Context, token Token) (bool, error) {
+	ids := []string{token.Id}
+	if sessionId, ok := token.Body["sid"].(string); ok {
+		ids = append(ids, sessionId)
+	}
+
+	return s.store.IsRevoked(c, token.UserId.String(), token.IssuedAt, ids...)
+}
+
+func (s *Service) InvalidateToken(c context.Context, token Token) error {
+	if token.Id == "" {
+		return errors.New("token has no id")
+	}
+
+	ttl := time.Until(token.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.store.RevokeToken(c, token.Id, ttl)
+}
+
+// InvalidateSession revokes every token carrying the given "sid" body claim.
+// Refresh tokens are already unusable once the session is gone, so only the
+// access token lifetime has to be covered.
+func (s *Service) InvalidateSession(c context.Context, sessionId string) error {
+	return s.store.RevokeToken(c, sessionId, time.Duration(s.config.AccessTokenTTL)*time.Second)
+}
+
+func (s *Service) InvalidateUserTokens(c context.Context, userID ulid.ULID) error {
+	ttl := max(s.config.AccessTokenTTL, s.config.RefreshTokenTTL)
+
+	return s.store.RevokeUser(c, userID.String(), time.Now(), time.Duration(ttl)*time.Second)
 }
@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const hmacKeyID = "hmac"
+
+type key struct {
+	id     string
+	method jwt.SigningMethod
+	sign   any
+	verify any
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func loadSigningKey(config Config) (*key, error) {
+	switch config.Algorithm {
+	case "", AlgorithmHS256:
+		if config.Secret == "" {
+			return nil, errors.New("HS256 requires a secret")
+		}
+		return &key{
+			id:     hmacKeyID,
+			method: jwt.SigningMethodHS256,
+			sign:   []byte(config.Secret),
+			verify: []byte(config.Secret),
+		}, nil
+	case AlgorithmRS256:
+		data, err := os.ReadFile(config.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return publicKey(privateKey, &privateKey.PublicKey)
+	case AlgorithmEdDSA:
+		data, err := os.ReadFile(config.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		edKey := privateKey.(ed25519.PrivateKey)
+		return publicKey(edKey, edKey.Public())
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", config.Algorithm)
+	}
+}
+
+func loadVerificationKey(path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key %s: %w", path, err)
+	}
+
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return publicKey(nil, rsaKey)
+	}
+	if edKey, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return publicKey(nil, edKey)
+	}
+
+	return nil, fmt.Errorf("unsupported public key: %s", path)
+}
+
+// publicKey builds a key whose id is derived from the public half, so the
+// signer and every verifier agree on the kid without extra configuration.
+func publicKey(sign any, verify any) (*key, error) {
+	var method jwt.SigningMethod
+	switch verify.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("unsupported public key type")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(verify)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+
+	return &key{
+		id:     base64.RawURLEncoding.EncodeToString(sum[:12]),
+		method: method,
+		sign:   sign,
+		verify: verify,
+	}, nil
+}
+
+func (k *key) jwk() (JWK, bool) {
+	switch pub := k.verify.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.id,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.id,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
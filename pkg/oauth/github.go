@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+var GitHubEndpoint = Endpoint{
+	AuthURL:     "https://github.com/login/oauth/authorize",
+	TokenURL:    "https://github.com/login/oauth/access_token",
+	UserInfoURL: "https://api.github.com/user",
+}
+
+// GitHubProvider covers GitHub, which speaks plain OAuth2 rather than OIDC:
+// the profile comes from the REST API and the address from /user/emails.
+type GitHubProvider struct {
+	config   Config
+	endpoint Endpoint
+}
+
+func NewGitHubProvider(config Config) *GitHubProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read:user", "user:email"}
+	}
+
+	return &GitHubProvider{
+		config:   config,
+		endpoint: GitHubEndpoint,
+	}
+}
+
+func (p *GitHubProvider) Name() string {
+	return ProviderGitHub
+}
+
+func (p *GitHubProvider) AuthCodeURL(c context.Context, state string, codeChallenge string) (string, error) {
+	return authCodeURL(p.config, p.endpoint, state, codeChallenge), nil
+}
+
+type gitHubUser struct {
+	Id    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (p *GitHubProvider) Exchange(c context.Context, code string, codeVerifier string) (Profile, error) {
+	accessToken, err := exchangeCode(c, p.config, p.endpoint, code, codeVerifier)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var user gitHubUser
+	if err = getJSON(c, p.endpoint.UserInfoURL, accessToken, &user); err != nil {
+		return Profile{}, fmt.Errorf("failed to fetch github user: %w", err)
+	}
+	if user.Id == 0 {
+		return Profile{}, fmt.Errorf("failed to fetch github user: missing id")
+	}
+
+	var emails []gitHubEmail
+	if err = getJSON(c, p.endpoint.UserInfoURL+"/emails", accessToken, &emails); err != nil {
+		return Profile{}, fmt.Errorf("failed to fetch github emails: %w", err)
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+
+	profile := Profile{
+		Subject: strconv.FormatInt(user.Id, 10),
+		Name:    name,
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+			break
+		}
+	}
+	return profile, nil
+}
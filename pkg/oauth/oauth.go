@@ -0,0 +1,148 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+	ProviderOIDC   = "oidc"
+)
+
+// Profile is the subset of the external account used to sign users in.
+type Profile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow with PKCE against one identity
+// provider.
+type Provider interface {
+	Name() string
+	AuthCodeURL(c context.Context, state string, codeChallenge string) (string, error)
+	Exchange(c context.Context, code string, codeVerifier string) (Profile, error)
+}
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type Endpoint struct {
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// CodeChallenge derives the S256 PKCE challenge for the verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authCodeURL(config Config, endpoint Endpoint, state string, codeChallenge string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", config.ClientID)
+	v.Set("redirect_uri", config.RedirectURL)
+	v.Set("scope", strings.Join(config.Scopes, " "))
+	v.Set("state", state)
+	v.Set("code_challenge", codeChallenge)
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(endpoint.AuthURL, "?") {
+		sep = "&"
+	}
+	return endpoint.AuthURL + sep + v.Encode()
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type tokenResponse struct {
+	errorResponse
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// exchangeCode redeems the authorization code for an access token.
+func exchangeCode(c context.Context, config Config, endpoint Endpoint, code string, codeVerifier string) (string, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", config.RedirectURL)
+	v.Set("client_id", config.ClientID)
+	v.Set("client_secret", config.ClientSecret)
+	v.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(c, http.MethodPost, endpoint.TokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token tokenResponse
+	if err = doJSON(req, &token); err != nil {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("failed to exchange code: %s: %s", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("failed to exchange code: empty access token")
+	}
+	return token.AccessToken, nil
+}
+
+func getJSON(c context.Context, url string, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(c, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e errorResponse
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s: %s", e.Error, e.ErrorDescription)
+		}
+		return fmt.Errorf("%s %s: unexpected status %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,107 @@
+package oauth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neokofg/callap-backend/pkg/oauth"
+	"github.com/neokofg/callap-backend/pkg/oauth/oauthtest"
+)
+
+const redirectURL = "http://localhost:3000/oauth/callback/oidc"
+
+func newTestProvider(t *testing.T) (*oauthtest.Server, *oauth.OIDCProvider) {
+	t.Helper()
+	server, err := oauthtest.NewServer()
+	if err != nil {
+		t.Fatalf("oauthtest.NewServer: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server, oauth.NewOIDCProvider(oauth.ProviderOIDC, server.URL, oauthtest.Config(redirectURL))
+}
+
+// authorize runs the flow up to the callback and returns the code.
+func authorize(t *testing.T, server *oauthtest.Server, provider *oauth.OIDCProvider, verifier string, user oauthtest.User) string {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", oauth.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := server.Authorize(authURL, user)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q, want %q", state, "state")
+	}
+	return code
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	server, provider := newTestProvider(t)
+	user := oauthtest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+	code := authorize(t, server, provider, "verifier", user)
+	profile, err := provider.Exchange(context.Background(), code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := oauth.Profile{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	if profile != want {
+		t.Errorf("profile = %+v, want %+v", profile, want)
+	}
+}
+
+func TestOIDCProviderEmailVerifiedClaim(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	for _, tt := range []struct {
+		claim any
+		want  bool
+	}{
+		{claim: true, want: true},
+		{claim: "true", want: true},
+		{claim: false, want: false},
+		{claim: "false", want: false},
+		{claim: nil, want: false},
+	} {
+		code := authorize(t, server, provider, "verifier", oauthtest.User{Subject: "sub", Email: "a@example.com", EmailVerified: tt.claim})
+		profile, err := provider.Exchange(context.Background(), code, "verifier")
+		if err != nil {
+			t.Fatalf("Exchange: %v", err)
+		}
+		if profile.EmailVerified != tt.want {
+			t.Errorf("email_verified %#v read as %v, want %v", tt.claim, profile.EmailVerified, tt.want)
+		}
+	}
+}
+
+func TestOIDCProviderRequiresVerifier(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	code := authorize(t, server, provider, "verifier", oauthtest.User{Subject: "sub"})
+	if _, err := provider.Exchange(context.Background(), code, "another verifier"); err == nil {
+		t.Errorf("Exchange with the wrong PKCE verifier succeeded")
+	}
+}
+
+func TestOIDCProviderCodeIsSingleUse(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	code := authorize(t, server, provider, "verifier", oauthtest.User{Subject: "sub"})
+	if _, err := provider.Exchange(context.Background(), code, "verifier"); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, "verifier"); err == nil {
+		t.Errorf("second Exchange of the same code succeeded")
+	}
+}
+
+func TestOIDCProviderIssuerMismatch(t *testing.T) {
+	server, _ := newTestProvider(t)
+
+	provider := oauth.NewOIDCProvider(oauth.ProviderOIDC, server.URL+"/other", oauthtest.Config(redirectURL))
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "challenge"); err == nil {
+		t.Errorf("discovery of a mismatched issuer succeeded")
+	}
+}